@@ -0,0 +1,405 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/IdontKer/RLarena/transposition"
+)
+
+// Long running games are inspected periodically and ended early, once one
+// side provably wins. Two analyses are used:
+//
+//   - pawn race: a pawn that can neither be blocked nor captured reaches the
+//     last row before any pawn of the opponent can, and the opponent cannot
+//     run out of moves (a draw) in the meantime
+//   - bounded search: a forced win within ADJUDICATION_SEARCH_DEPTH plies
+//
+// Games are only adjudicated as wins, never as draws.
+const (
+	ADJUDICATION_MIN_PLIES    = 40 // default, see ADJUDICATION_MIN_PLIES env variable
+	ADJUDICATION_SEARCH_DEPTH = 8
+	ADJUDICATION_MAX_NODES    = 20000 // per game and inspection, about 0.1s on 16x16
+	ADJUDICATION_TABLE_BITS   = 18
+)
+
+type Adjudicator struct {
+	minPlies int
+	table    *transposition.Table[int8]
+	nodes    int
+	checked  map[int]int // game id -> number of plies at the last inspection
+	moves    [][]BitMove // move buffer per remaining depth of the bitboard search
+}
+
+var adjudicator = NewAdjudicator(ADJUDICATION_MIN_PLIES)
+
+func NewAdjudicator(minPlies int) *Adjudicator {
+	return &Adjudicator{
+		minPlies: minPlies,
+		table:    transposition.New[int8](ADJUDICATION_TABLE_BITS),
+		checked:  make(map[int]int),
+	}
+}
+
+// loadAdjudicationConfig reads the number of plies after which games are
+// inspected from ADJUDICATION_MIN_PLIES. A negative value disables the
+// adjudication.
+func loadAdjudicationConfig() {
+	value := os.Getenv("ADJUDICATION_MIN_PLIES")
+	if value == "" {
+		return
+	}
+	minPlies, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Error("Invalid ADJUDICATION_MIN_PLIES, using the default", "value", value, "default", ADJUDICATION_MIN_PLIES)
+		return
+	}
+	adjudicator.minPlies = minPlies
+	slog.Info("Adjudication config loaded", "minPlies", minPlies)
+}
+
+// adjudicateGames inspects all active games and ends those with a decided
+// outcome. It is not safe for concurrent use and runs in the periodic job.
+func (a *Adjudicator) adjudicateGames() error {
+	if a.minPlies < 0 {
+		return nil
+	}
+
+	var games []Game
+	if activeGames.Loaded() {
+		games = activeGames.GetAll()
+	} else {
+		var err error
+		games, err = DB_Get_Active_Games()
+		if err != nil {
+			return err
+		}
+	}
+
+	active := make(map[int]int, len(games))
+	for i := range games {
+		game := &games[i]
+		plies := len(game.GameState.History)
+		active[game.ID] = plies
+		if last, ok := a.checked[game.ID]; plies < a.minPlies || (ok && last == plies) {
+			continue
+		}
+
+		winner, reason := a.Adjudicate(game.GameState)
+		if winner == 0 {
+			continue
+		}
+
+		err := endGame(game, winner, TERMINATION_ADJUDICATION)
+		if err != nil {
+			// the game has changed meanwhile, inspect again next time
+			slog.Warn("Error adjudicating game", "id", game.ID, "error", err)
+			continue
+		}
+		slog.Info("Game adjudicated", "id", game.ID, "winner", winner, "reason", reason, "plies", plies)
+	}
+
+	// forget finished games, remember the inspected positions
+	a.checked = active
+	return nil
+}
+
+// Adjudicate returns the player that provably wins the position together with
+// a description of the proof, or 0 if the outcome is open.
+func (a *Adjudicator) Adjudicate(g *GameState) (int, string) {
+	if g.IsEnd() {
+		return 0, ""
+	}
+
+	for _, player := range []int{1, 2} {
+		if row, col, ok := winsPawnRace(g, player); ok {
+			return player, fmt.Sprintf("unstoppable pawn on %s", squareName(row, col))
+		}
+	}
+
+	// the bitboard is faster but only knows the standard rules
+	state := g.Clone()
+	board, err := BitboardFromState(g)
+	if err != nil {
+		board = nil
+	}
+
+	a.table.Clear()
+	a.nodes = 0
+	for depth := 1; depth <= ADJUDICATION_SEARCH_DEPTH; depth++ {
+		var result int8
+		if board != nil {
+			result = a.solveBitboard(board, depth)
+		} else {
+			result = a.solve(state, depth)
+		}
+		if result != 0 {
+			winner := state.NextPlayer()
+			if result < 0 {
+				winner = 3 - winner
+			}
+			return winner, fmt.Sprintf("forced win within %d plies", depth)
+		}
+		if a.nodes >= ADJUDICATION_MAX_NODES {
+			break
+		}
+	}
+	return 0, ""
+}
+
+// solve searches the position to the given depth. It returns 1 if the side
+// to move forces a win, -1 if it loses by force and 0 otherwise (draw or not
+// decided within the depth).
+func (a *Adjudicator) solve(g *GameState, depth int) int8 {
+	switch winner := g.GetWinner(); winner {
+	case 0:
+	case g.NextPlayer():
+		return 1
+	case -1:
+		return 0
+	default:
+		return -1
+	}
+	if depth == 0 {
+		return 0
+	}
+
+	// proven results hold at any depth, open results only up to the
+	// depth they were searched with
+	if value, stored, ok := a.table.Get(g.Hash()); ok && (value != 0 || stored >= depth) {
+		return value
+	}
+	if a.nodes >= ADJUDICATION_MAX_NODES {
+		return 0
+	}
+	a.nodes++
+
+	result := int8(-1)
+	it := g.Moves()
+	for move, ok := it.Next(); ok; move, ok = it.Next() {
+		delta := g.MakeMove(move)
+		value := -a.solve(g, depth-1)
+		g.UnmakeMove(delta)
+
+		if value > 0 {
+			result = 1
+			break
+		}
+		if value == 0 {
+			result = 0
+		}
+	}
+
+	if a.nodes < ADJUDICATION_MAX_NODES || result != 0 {
+		a.table.Put(g.Hash(), depth, result)
+	}
+	return result
+}
+
+// solveBitboard is solve on a bitboard, the hashes and thereby the table
+// entries are the same.
+func (a *Adjudicator) solveBitboard(b *Bitboard, depth int) int8 {
+	switch winner := b.GetWinner(); winner {
+	case 0:
+	case b.NextPlayer():
+		return 1
+	case -1:
+		return 0
+	default:
+		return -1
+	}
+	if depth == 0 {
+		return 0
+	}
+
+	if value, stored, ok := a.table.Get(b.Hash); ok && (value != 0 || stored >= depth) {
+		return value
+	}
+	if a.nodes >= ADJUDICATION_MAX_NODES {
+		return 0
+	}
+	a.nodes++
+
+	for len(a.moves) <= depth {
+		a.moves = append(a.moves, make([]BitMove, 0, 3*BITBOARD_MAX_SIZE))
+	}
+	moves := b.GenerateMoves(a.moves[depth][:0])
+	a.moves[depth] = moves
+
+	result := int8(-1)
+	for _, move := range moves {
+		b.Apply(move)
+		value := -a.solveBitboard(b, depth-1)
+		b.Undo(move)
+
+		if value > 0 {
+			result = 1
+			break
+		}
+		if value == 0 {
+			result = 0
+		}
+	}
+
+	if a.nodes < ADJUDICATION_MAX_NODES || result != 0 {
+		a.table.Put(b.Hash, depth, result)
+	}
+	return result
+}
+
+func forwardDir(player int) int {
+	if player == 1 {
+		return 1
+	}
+	return -1
+}
+
+func homeRow(g *GameState, player int) int {
+	if player == 1 {
+		return 0
+	}
+	return g.Rows - 1
+}
+
+func goalRow(g *GameState, player int) int {
+	return homeRow(g, 3-player)
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// movesToGoal is the number of moves a pawn needs to reach the last row on
+// an empty board.
+func movesToGoal(g *GameState, player int, row int) int {
+	n := absInt(goalRow(g, player) - row)
+	if g.Rules.DoubleStep && row == homeRow(g, player) && n >= 2 {
+		n--
+	}
+	return n
+}
+
+// isUnstoppable reports whether the pawn can neither be blocked nor captured
+// on its way to the last row: its column ahead is empty and no opponent pawn
+// ahead is close enough to reach an adjacent column by captures in time.
+func isUnstoppable(g *GameState, player int, row int, col int) bool {
+	dir := forwardDir(player)
+	goal := goalRow(g, player)
+
+	// a pawn that has just made a double step may be captured en passant
+	if epRow, epCol, ok := g.enPassantSquare(); ok && epCol == col && epRow == row-dir {
+		for _, x := range []int{col - 1, col + 1} {
+			if x >= 0 && x < g.Cols && g.Board[row][x] == 3-player {
+				return false
+			}
+		}
+	}
+
+	for y := row + dir; y != goal+dir; y += dir {
+		if g.Board[y][col] != 0 {
+			return false
+		}
+		dist := absInt(y - row)
+		for x := max(col-dist, 0); x <= min(col+dist, g.Cols-1); x++ {
+			if g.Board[y][x] == 3-player {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// guaranteedMoves is a lower bound on the number of moves the player can make
+// before running out of moves, while the opponent only moves an unstoppable
+// pawn. It counts the free cells ahead of the pawns that can never capture on
+// their way, since nothing else can enter or leave these lanes.
+func guaranteedMoves(g *GameState, player int) int {
+	dir := forwardDir(player)
+	epRow, epCol, ep := g.enPassantSquare()
+	total := 0
+	for row := 0; row < g.Rows; row++ {
+		for col := 0; col < g.Cols; col++ {
+			if g.Board[row][col] != player {
+				continue
+			}
+
+			free := 0
+			capture := false
+			for y := row; y >= 0 && y < g.Rows; y += dir {
+				if y != row && g.Board[y][col] != 0 {
+					break
+				}
+				if y != row {
+					free++
+				}
+				next := y + dir
+				if next < 0 || next >= g.Rows {
+					break
+				}
+				for _, x := range []int{col - 1, col + 1} {
+					if x >= 0 && x < g.Cols && (g.Board[next][x] == 3-player || (ep && next == epRow && x == epCol)) {
+						capture = true
+					}
+				}
+			}
+			if capture {
+				continue
+			}
+			// a double step uses two free cells at once
+			if g.Rules.DoubleStep && row == homeRow(g, player) && free >= 2 {
+				free--
+			}
+			total += free
+		}
+	}
+	return total
+}
+
+// winsPawnRace reports whether the player has an unstoppable pawn that wins
+// the race to the last row. It returns the square of that pawn.
+func winsPawnRace(g *GameState, player int) (int, int, bool) {
+	opponent := 3 - player
+
+	// fastest unstoppable pawn of the player
+	best, bestRow, bestCol := -1, 0, 0
+	// fastest pawn of the opponent, ignoring all obstacles
+	fastest := -1
+	for row := 0; row < g.Rows; row++ {
+		for col := 0; col < g.Cols; col++ {
+			switch g.Board[row][col] {
+			case player:
+				n := movesToGoal(g, player, row)
+				if (best < 0 || n < best) && isUnstoppable(g, player, row, col) {
+					best, bestRow, bestCol = n, row, col
+				}
+			case opponent:
+				n := movesToGoal(g, opponent, row)
+				if fastest < 0 || n < fastest {
+					fastest = n
+				}
+			}
+		}
+	}
+	if best < 0 || fastest < 0 {
+		return 0, 0, false
+	}
+
+	// moves of the opponent before the pawn arrives
+	turns := best
+	if g.NextPlayer() == player {
+		turns--
+	}
+	if fastest <= turns {
+		return 0, 0, false
+	}
+	// the opponent must not be able to escape into a draw by running out of moves
+	if !g.Rules.StalemateLoses && guaranteedMoves(g, opponent) < turns {
+		return 0, 0, false
+	}
+	return bestRow, bestCol, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// The admin routes are for the operators of the server, they need the token
+// of ADMIN_TOKEN and are disabled without it.
+var adminToken = ""
+
+func loadAdminConfig() {
+	adminToken = os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		slog.Info("ADMIN_TOKEN is not set, the admin routes are disabled")
+	}
+}
+
+// authenticateAdmin checks the admin token of the request, or writes the
+// error response and returns false.
+func authenticateAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		writeError(w, ERR_NOT_FOUND, "Not found")
+		return false
+	}
+	token := requestToken(r)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, ERR_UNAUTHORIZED, "Admin token is required")
+		return false
+	}
+	return true
+}
+
+// serveGameCacheCheck compares the cache of active games with the database,
+// it reads all active games.
+func serveGameCacheCheck(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(w, r) {
+		return
+	}
+
+	problems, err := activeGames.CheckConsistency()
+	if err != nil {
+		slog.Error("Error checking the game cache", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Consistent bool     `json:"consistent"`
+		Problems   []string `json:"problems"`
+	}{
+		Consistent: len(problems) == 0,
+		Problems:   problems,
+	})
+}
+
+func InitHttpHandler_Admin() {
+	mux.HandleFunc("GET /admin/cache/check", serveGameCacheCheck)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// The versioned API lives below API_PREFIX. Its routes are listed in
+// apiRoutes, which is the source of the OpenAPI document as well. The routes
+// of the time before are kept as deprecated aliases, see deprecated.
+const API_PREFIX = "/api/v1"
+
+const (
+	AUTH_NONE   = ""
+	AUTH_PLAYER = "player" // token of a bot
+	AUTH_OWNER  = "owner"  // session of an owner
+)
+
+type apiParam struct {
+	Name        string
+	Type        string // "string" or "integer"
+	Required    bool
+	Description string
+}
+
+type apiRoute struct {
+	Method      string
+	Path        string // below API_PREFIX
+	Handler     http.HandlerFunc
+	Tag         string
+	Summary     string
+	Auth        string
+	Query       []apiParam
+	Headers     []apiParam
+	Request     any  // value of the body type, a string for text bodies, nil for none
+	Response    any  // value of the body type, nil for none
+	Status      int  // status on success, 0 for 200 OK
+	Created     bool // answers 201 Created with the same body if a resource was created
+	EventStream bool
+}
+
+type nameRequest struct {
+	Name string `json:"name"`
+}
+
+var apiRoutes = []apiRoute{
+	// players
+	{Method: "POST", Path: "/players", Handler: serveCreatePlayer, Tag: "players",
+		Summary: "Sign up a bot without owner, the token is shown only once",
+		Request: nameRequest{}, Response: NewBot{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/players", Handler: serveDisplayPlayers, Tag: "players",
+		Summary: "All players with their ratings", Response: []Player{}},
+	{Method: "GET", Path: "/players/me", Handler: serveGetPlayerByToken, Tag: "players",
+		Summary: "The player of the token", Auth: AUTH_PLAYER, Response: Player{}},
+	{Method: "GET", Path: "/players/me/games", Handler: serveActiveGamesUser, Tag: "players",
+		Summary: "Running games of the player, split by the player to move", Auth: AUTH_PLAYER, Response: ActiveGames{}},
+	{Method: "PUT", Path: "/players/me/version", Handler: serveSetVersion, Tag: "players",
+		Summary: "Declare the version of the bot", Auth: AUTH_PLAYER, Created: true,
+		Request: struct {
+			Version string `json:"version"`
+		}{}, Response: PlayerVersion{}},
+	{Method: "GET", Path: "/players/me/tokens", Handler: serveListTokens, Tag: "players",
+		Summary: "Tokens of the player", Auth: AUTH_PLAYER, Response: []ApiToken{}},
+	{Method: "POST", Path: "/players/me/tokens", Handler: serveCreateToken, Tag: "players",
+		Summary: "Create an additional token", Auth: AUTH_PLAYER, Response: NewToken{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/players/me/tokens/rotate", Handler: serveRotateToken, Tag: "players",
+		Summary: "Replace the token of the request by a new one", Auth: AUTH_PLAYER, Response: NewToken{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/players/me/tokens/{id}", Handler: serveRevokeToken, Tag: "players",
+		Summary: "Revoke a token", Auth: AUTH_PLAYER, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/players/{id}/profile", Handler: serveProfile, Tag: "players",
+		Summary: "Statistics of a player", Response: PlayerProfile{}},
+	{Method: "GET", Path: "/players/by-name/{name}/profile", Handler: serveProfileByName, Tag: "players",
+		Summary: "Statistics of a player by name", Response: PlayerProfile{}},
+
+	// games
+	{Method: "GET", Path: "/games", Handler: serveGames, Tag: "games",
+		Summary: "All games, oldest first", Response: []Game{},
+		Query: []apiParam{{Name: "page", Type: "integer", Description: "page starting at 1"}}},
+	{Method: "POST", Path: "/games", Handler: serveCreateCustomGame, Tag: "games",
+		Summary: "Start an unrated game from a custom position", Auth: AUTH_PLAYER,
+		Request: CustomGameRequest{}, Response: Game{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/games/active", Handler: serveActiveGames, Tag: "games",
+		Summary: "All running games", Response: []Game{}},
+	{Method: "POST", Path: "/games/actions", Handler: servePerformActionBulk, Tag: "games",
+		Summary: "Play in several games at once, one action per game", Auth: AUTH_PLAYER,
+		Request: []BulkAction{}, Response: BulkActionResult{}},
+	{Method: "POST", Path: "/games/records", Handler: serveImportRecords, Tag: "games",
+		Summary: "Check game records", Request: "", Response: ImportResults{}},
+	{Method: "GET", Path: "/games/{id}", Handler: serveGameState, Tag: "games",
+		Summary: "A game with its state", Response: Game{}},
+	{Method: "GET", Path: "/games/{id}/position", Handler: serveGamePosition, Tag: "games",
+		Summary: "The position of a game in text form", Response: GamePosition{}},
+	{Method: "GET", Path: "/games/{id}/record", Handler: serveGameRecord, Tag: "games",
+		Summary: "The record of a game", Response: ""},
+	{Method: "GET", Path: "/games/{id}/events", Handler: serveGameEvents, Tag: "games",
+		Summary: "Moves and the end of a game as server-sent events", EventStream: true,
+		Headers: []apiParam{
+			{Name: "Last-Event-ID", Type: "integer", Description: "number of plies seen, resumes the stream with the next move instead of a snapshot"},
+		}},
+	{Method: "POST", Path: "/games/{id}/actions", Handler: servePerformAction, Tag: "games",
+		Summary: "Play a move", Auth: AUTH_PLAYER, Request: Turn{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/games/{id}/moves", Handler: servePerformMove, Tag: "games",
+		Summary: "Play a move in algebraic notation, e.g. a2-a3", Auth: AUTH_PLAYER, Request: "", Status: http.StatusCreated},
+	{Method: "POST", Path: "/games/{id}/resign", Handler: serveResign, Tag: "games",
+		Summary: "Resign the game", Auth: AUTH_PLAYER, Response: Game{}},
+	{Method: "POST", Path: "/games/{id}/draw", Handler: serveDrawOffer, Tag: "games",
+		Summary: "Offer a draw, or accept the offer of the opponent", Auth: AUTH_PLAYER, Response: Game{}},
+	{Method: "DELETE", Path: "/games/{id}/draw", Handler: serveDrawDecline, Tag: "games",
+		Summary: "Decline the draw offer of the opponent", Auth: AUTH_PLAYER, Response: Game{}},
+	{Method: "GET", Path: "/position", Handler: serveAnalyzePosition, Tag: "games",
+		Summary: "Parse a position and play moves in it", Response: PositionView{},
+		Query: []apiParam{
+			{Name: "pos", Type: "string", Required: true, Description: "position, e.g. 5x3 ppp/3/3/3/PPP 1"},
+			{Name: "moves", Type: "string", Description: "comma separated moves"},
+		}},
+	{Method: "GET", Path: "/events", Handler: serveGlobalEvents, Tag: "games",
+		Summary: "Starts and ends of games and rating changes as server-sent events", EventStream: true},
+
+	// statistics
+	{Method: "GET", Path: "/stats/h2h", Handler: serveHeadToHead, Tag: "stats",
+		Summary: "Results between two players", Response: HeadToHead{},
+		Query: []apiParam{
+			{Name: "p1", Type: "integer", Required: true, Description: "id of the first player"},
+			{Name: "p2", Type: "integer", Required: true, Description: "id of the second player"},
+		}},
+	{Method: "GET", Path: "/stats/matrix", Handler: serveResultMatrix, Tag: "stats",
+		Summary: "Results between the top players", Response: ResultMatrix{},
+		Query: []apiParam{{Name: "n", Type: "integer", Description: "number of players"}}},
+
+	// teams
+	{Method: "GET", Path: "/teams", Handler: serveTeamStandings, Tag: "teams",
+		Summary: "Team standings", Response: []TeamStanding{}},
+	{Method: "GET", Path: "/teams/{id}", Handler: serveTeam, Tag: "teams",
+		Summary: "A team with its bots", Response: TeamDetails{}},
+
+	// owners
+	{Method: "POST", Path: "/owners", Handler: serveOwnerRegister, Tag: "owners",
+		Summary: "Create an owner account", Request: ownerCredentials{}, Response: Owner{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/owner/session", Handler: serveOwnerLogin, Tag: "owners",
+		Summary: "Log in, the session is set as cookie as well", Request: ownerCredentials{}, Response: OwnerSession{}},
+	{Method: "DELETE", Path: "/owner/session", Handler: serveOwnerLogout, Tag: "owners",
+		Summary: "Log out", Auth: AUTH_OWNER, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/owner", Handler: serveOwnerOverview, Tag: "owners",
+		Summary: "The owner with the results of all bots", Auth: AUTH_OWNER, Response: OwnerOverview{}},
+	{Method: "POST", Path: "/owner/bots", Handler: serveOwnerCreateBot, Tag: "owners",
+		Summary: "Create a bot", Auth: AUTH_OWNER, Request: nameRequest{}, Response: NewBot{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/owner/bots/{id}", Handler: serveOwnerRetireBot, Tag: "owners",
+		Summary: "Retire a bot, it resigns its running games", Auth: AUTH_OWNER, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/owner/bots/{id}/tokens", Handler: serveOwnerBotTokens, Tag: "owners",
+		Summary: "Tokens of a bot", Auth: AUTH_OWNER, Response: []ApiToken{}},
+	{Method: "POST", Path: "/owner/bots/{id}/tokens", Handler: serveOwnerCreateBotToken, Tag: "owners",
+		Summary: "Create a token for a bot", Auth: AUTH_OWNER, Response: NewToken{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/owner/bots/{id}/tokens/{tokenID}", Handler: serveOwnerRevokeBotToken, Tag: "owners",
+		Summary: "Revoke a token of a bot", Auth: AUTH_OWNER, Status: http.StatusNoContent},
+	{Method: "PUT", Path: "/owner/bots/{id}/team", Handler: serveOwnerJoinTeam, Tag: "owners",
+		Summary: "A bot joins a team", Auth: AUTH_OWNER,
+		Request: struct {
+			JoinCode string `json:"join_code"`
+		}{}, Response: Team{}},
+	{Method: "DELETE", Path: "/owner/bots/{id}/team", Handler: serveOwnerLeaveTeam, Tag: "owners",
+		Summary: "A bot leaves its team", Auth: AUTH_OWNER, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/owner/teams", Handler: serveOwnerTeams, Tag: "owners",
+		Summary: "Teams managed by the owner with their join codes", Auth: AUTH_OWNER, Response: []Team{}},
+	{Method: "POST", Path: "/owner/teams", Handler: serveOwnerCreateTeam, Tag: "owners",
+		Summary: "Create a team", Auth: AUTH_OWNER, Request: nameRequest{}, Response: Team{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/owner/teams/{id}/members/{playerID}", Handler: serveOwnerRemoveMember, Tag: "owners",
+		Summary: "Remove a bot from a managed team", Auth: AUTH_OWNER, Status: http.StatusNoContent},
+
+	// server
+	{Method: "GET", Path: "/config/boards", Handler: serveBoardConfig, Tag: "server",
+		Summary: "Board sizes of new games", Response: []BoardInfo{}},
+	{Method: "GET", Path: "/config/teams", Handler: serveTeamConfig, Tag: "server",
+		Summary: "Team rules", Response: map[string]bool{}},
+	{Method: "GET", Path: "/metrics/ratelimit", Handler: serveRateLimitMetrics, Tag: "server",
+		Summary: "Allowed and throttled requests per rate limit group", Response: map[string]any{}},
+}
+
+var pathWildcard = regexp.MustCompile(`\{(\w+)\}`)
+
+// deprecated marks a route of the unversioned API. The response links to
+// the route of the versioned API that replaces it.
+func deprecated(successor string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := pathWildcard.ReplaceAllStringFunc(successor, func(wildcard string) string {
+			return r.PathValue(wildcard[1 : len(wildcard)-1])
+		})
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+API_PREFIX+path+`>; rel="successor-version"`)
+		handler(w, r)
+	}
+}
+
+func InitHttpHandler_API() {
+	for _, route := range apiRoutes {
+		mux.HandleFunc(route.Method+" "+API_PREFIX+route.Path, route.Handler)
+	}
+	mux.HandleFunc("GET "+API_PREFIX+"/openapi.json", serveOpenAPI)
+}
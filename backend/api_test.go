@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var registerAPI sync.Once
+
+// testSeed is data inserted into the test database right before the named
+// migration is applied, to test the migration of existing rows.
+type testSeed struct {
+	before string // file name of the migration
+	sql    string
+}
+
+// openTestDB creates a database from the migrations and points DB_PATH to it.
+func openTestDB(t *testing.T, seeds ...testSeed) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.db")
+	t.Setenv("DB_PATH", path)
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	files, err := filepath.Glob(filepath.Join("migrations", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		for _, seed := range seeds {
+			if seed.before != filepath.Base(file) {
+				continue
+			}
+			if _, err := db.Exec(seed.sql); err != nil {
+				t.Fatalf("seed before %s: %v", file, err)
+			}
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+		up = strings.NewReplacer("-- +goose Up", "", "-- +goose StatementBegin", "", "-- +goose StatementEnd", "").Replace(up)
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+}
+
+// specTest sends requests to the versioned API and checks the responses
+// against the OpenAPI document.
+type specTest struct {
+	t       *testing.T
+	spec    map[string]any
+	covered map[string]bool // routes answered with success
+}
+
+// discardLogs silences the logs of the server for the test.
+func discardLogs(t *testing.T) {
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+}
+
+func newSpecTest(t *testing.T) *specTest {
+	discardLogs(t)
+	openTestDB(t)
+	registerAPI.Do(InitHttpHandler_API)
+
+	document, err := openAPIJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(document, &spec); err != nil {
+		t.Fatal(err)
+	}
+	return &specTest{t: t, spec: spec, covered: make(map[string]bool)}
+}
+
+// request sends the request and fails the test if the response is not
+// described by the document. header holds pairs of names and values.
+func (s *specTest) request(method string, path string, token string, body string, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(method, API_PREFIX+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	_, pattern := mux.Handler(req)
+	_, specPath, _ := strings.Cut(pattern, " ")
+	operation, ok := s.lookup("paths", specPath, strings.ToLower(method)).(map[string]any)
+	if !ok {
+		s.t.Errorf("%s %s: no operation for %q", method, path, pattern)
+		return httptest.NewRecorder()
+	}
+
+	// event streams end when the client disconnects
+	responses := operation["responses"].(map[string]any)
+	if _, stream := s.lookupIn(responses, "200", "content", "text/event-stream").(map[string]any); stream {
+		ctx, cancel := context.WithTimeout(req.Context(), 50*time.Millisecond)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	name := fmt.Sprintf("%s %s (%d)", method, path, rec.Code)
+	response, documented := responses[strconv.Itoa(rec.Code)].(map[string]any)
+	if !documented {
+		response = s.resolve(responses["default"]).(map[string]any)
+	}
+	s.checkBody(name, response, rec)
+
+	if documented && rec.Code < 300 {
+		s.covered[pattern] = true
+	}
+	return rec
+}
+
+// expect sends the request and fails the test unless the status matches.
+func (s *specTest) expect(status int, method string, path string, token string, body string, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	rec := s.request(method, path, token, body, header...)
+	if rec.Code != status {
+		s.t.Fatalf("%s %s: status %d, want %d: %s", method, path, rec.Code, status, rec.Body.String())
+	}
+	return rec
+}
+
+func (s *specTest) checkBody(name string, response map[string]any, rec *httptest.ResponseRecorder) {
+	s.t.Helper()
+	content, _ := response["content"].(map[string]any)
+	if len(content) == 0 {
+		if rec.Body.Len() != 0 {
+			s.t.Errorf("%s: undocumented body %q", name, rec.Body.String())
+		}
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		s.t.Errorf("%s: invalid content type %q", name, rec.Header().Get("Content-Type"))
+		return
+	}
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		s.t.Errorf("%s: undocumented content type %q", name, mediaType)
+		return
+	}
+	if mediaType != "application/json" {
+		return
+	}
+
+	var value any
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		s.t.Errorf("%s: invalid JSON: %v", name, err)
+		return
+	}
+	if err := s.validate(media["schema"], value, "body"); err != nil {
+		s.t.Errorf("%s: %v", name, err)
+	}
+}
+
+// lookup follows the keys through the document.
+func (s *specTest) lookup(keys ...string) any {
+	return s.lookupIn(s.spec, keys...)
+}
+
+func (s *specTest) lookupIn(node any, keys ...string) any {
+	for _, key := range keys {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = object[key]
+	}
+	return node
+}
+
+func (s *specTest) resolve(node any) any {
+	object, ok := node.(map[string]any)
+	if !ok {
+		return node
+	}
+	ref, ok := object["$ref"].(string)
+	if !ok {
+		return node
+	}
+	return s.resolve(s.lookup(strings.Split(strings.TrimPrefix(ref, "#/"), "/")...))
+}
+
+// validate checks the decoded JSON value against the subset of JSON Schema
+// used by the generated document.
+func (s *specTest) validate(node any, value any, at string) error {
+	schema, ok := s.resolve(node).(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: invalid schema %v", at, node)
+	}
+	if value == nil {
+		if schema["nullable"] == true || len(schema) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := s.validate(sub, value, at); err != nil {
+				return err
+			}
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || allowed == value
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: %T is not an object", at, value)
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing %q", at, name)
+			}
+		}
+		for name, field := range object {
+			if property, ok := properties[name]; ok {
+				if err := s.validate(property, field, at+"."+name); err != nil {
+					return err
+				}
+			} else if additional, ok := schema["additionalProperties"]; ok {
+				if err := s.validate(additional, field, at+"."+name); err != nil {
+					return err
+				}
+			} else if properties != nil {
+				return fmt.Errorf("%s: undocumented field %q", at, name)
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: %T is not an array", at, value)
+		}
+		if n, ok := schema["minItems"].(float64); ok && float64(len(array)) < n {
+			return fmt.Errorf("%s: %d items, want at least %v", at, len(array), n)
+		}
+		if n, ok := schema["maxItems"].(float64); ok && float64(len(array)) > n {
+			return fmt.Errorf("%s: %d items, want at most %v", at, len(array), n)
+		}
+		for i, item := range array {
+			if err := s.validate(schema["items"], item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: %T is not a string", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: %T is not a boolean", at, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: %v is not an integer", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: %T is not a number", at, value)
+		}
+	}
+	return nil
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var value T
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	return value
+}
+
+// firstMove returns the first legal move of the running game.
+func firstMove(t *testing.T, id int) Turn {
+	t.Helper()
+	game, err := DB_Get_Game(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return game.GameState.PossibleMoves()[0]
+}
+
+func TestAPIRoutesMatchSpec(t *testing.T) {
+	s := newSpecTest(t)
+
+	// players
+	alpha := decode[NewBot](t, s.expect(http.StatusCreated, "POST", "/players", "", `{"name":"alpha"}`))
+	beta := decode[NewBot](t, s.expect(http.StatusCreated, "POST", "/players", "", `{"name":"beta"}`))
+	s.expect(http.StatusOK, "GET", "/players", "", "")
+	s.expect(http.StatusOK, "GET", "/players/me", alpha.Token, "")
+	s.request("PUT", "/players/me/version", alpha.Token, `{"version":"v1"}`)
+	s.expect(http.StatusOK, "GET", "/players/me/tokens", alpha.Token, "")
+	extra := decode[NewToken](t, s.expect(http.StatusCreated, "POST", "/players/me/tokens", alpha.Token, ""))
+	s.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/players/me/tokens/%d", extra.ID), alpha.Token, "")
+	alpha.Token = decode[NewToken](t, s.expect(http.StatusCreated, "POST", "/players/me/tokens/rotate", alpha.Token, "")).Token
+
+	// games
+	request := fmt.Sprintf(`{"opponent_id":%d,"position":"5x5 ppppp/5/5/5/PPPPP 1","player":1}`, beta.ID)
+	game := decode[Game](t, s.expect(http.StatusCreated, "POST", "/games", alpha.Token, request))
+	gamePath := fmt.Sprintf("/games/%d", game.ID)
+	s.expect(http.StatusOK, "GET", "/players/me/games", alpha.Token, "")
+	s.expect(http.StatusOK, "GET", "/games?page=1", "", "")
+	s.expect(http.StatusOK, "GET", "/games/active", "", "")
+	s.expect(http.StatusOK, "GET", gamePath, "", "")
+	s.expect(http.StatusOK, "GET", gamePath+"/position", "", "")
+	s.expect(http.StatusOK, "GET", gamePath+"/events", "", "")
+
+	move, _ := json.Marshal(firstMove(t, game.ID))
+	s.expect(http.StatusCreated, "POST", gamePath+"/actions", alpha.Token, string(move))
+	s.expect(http.StatusCreated, "POST", gamePath+"/moves", beta.Token, firstMove(t, game.ID).Notation())
+	bulk, _ := json.Marshal([]BulkAction{{GameID: game.ID, Action: firstMove(t, game.ID)}})
+	s.expect(http.StatusOK, "POST", "/games/actions", alpha.Token, string(bulk))
+	s.expect(http.StatusOK, "GET", gamePath+"/events", "", "", "Last-Event-ID", "1")
+	s.expect(http.StatusOK, "POST", gamePath+"/draw", beta.Token, "")
+	s.expect(http.StatusOK, "DELETE", gamePath+"/draw", alpha.Token, "")
+	s.expect(http.StatusOK, "POST", gamePath+"/resign", alpha.Token, "")
+	record := s.expect(http.StatusOK, "GET", gamePath+"/record", "", "").Body.String()
+	s.expect(http.StatusOK, "POST", "/games/records", "", record)
+	s.expect(http.StatusOK, "GET", "/position?pos="+url.QueryEscape("5x3 ppp/3/3/3/PPP 1"), "", "")
+	s.expect(http.StatusOK, "GET", "/events", "", "")
+
+	// errors are described by the default response
+	s.expect(http.StatusNotFound, "GET", "/games/999999", "", "")
+	s.expect(http.StatusConflict, "POST", gamePath+"/resign", alpha.Token, "")
+	s.expect(http.StatusUnauthorized, "GET", "/players/me", "made-up", "")
+	s.expect(http.StatusOK, "GET", "/position?pos="+url.QueryEscape("3x2 1p/2/P1 1")+"&moves=a1-a2,b3-b2,a2-a3", "", "")
+	s.expect(http.StatusBadRequest, "GET", "/position?pos="+url.QueryEscape("3x2 1p/2/P1 1")+"&moves=a1-a2,b3-b2,a2-a3,b2-b1", "", "")
+
+	// statistics
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/stats/h2h?p1=%d&p2=%d", alpha.ID, beta.ID), "", "")
+	s.expect(http.StatusOK, "GET", "/stats/matrix?n=5", "", "")
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/players/%d/profile", alpha.ID), "", "")
+	s.expect(http.StatusOK, "GET", "/players/by-name/alpha/profile", "", "")
+
+	// owners and teams
+	credentials := `{"username":"owner","password":"password1"}`
+	s.expect(http.StatusCreated, "POST", "/owners", "", credentials)
+	session := decode[OwnerSession](t, s.expect(http.StatusOK, "POST", "/owner/session", "", credentials)).Session
+	bot := decode[NewBot](t, s.expect(http.StatusCreated, "POST", "/owner/bots", session, `{"name":"gamma"}`))
+	botPath := fmt.Sprintf("/owner/bots/%d", bot.ID)
+	s.expect(http.StatusOK, "GET", "/owner", session, "")
+	s.expect(http.StatusOK, "GET", botPath+"/tokens", session, "")
+	botToken := decode[NewToken](t, s.expect(http.StatusCreated, "POST", botPath+"/tokens", session, ""))
+	s.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("%s/tokens/%d", botPath, botToken.ID), session, "")
+
+	team := decode[Team](t, s.expect(http.StatusCreated, "POST", "/owner/teams", session, `{"name":"team"}`))
+	join := fmt.Sprintf(`{"join_code":%q}`, team.JoinCode)
+	s.expect(http.StatusOK, "GET", "/owner/teams", session, "")
+	s.expect(http.StatusOK, "PUT", botPath+"/team", session, join)
+	s.expect(http.StatusOK, "GET", "/teams", "", "")
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/teams/%d", team.ID), "", "")
+	s.expect(http.StatusNoContent, "DELETE", botPath+"/team", session, "")
+	s.expect(http.StatusOK, "PUT", botPath+"/team", session, join)
+	s.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/owner/teams/%d/members/%d", team.ID, bot.ID), session, "")
+	s.expect(http.StatusNoContent, "DELETE", botPath, session, "")
+	s.expect(http.StatusNoContent, "DELETE", "/owner/session", session, "")
+
+	// server
+	s.expect(http.StatusOK, "GET", "/config/boards", "", "")
+	s.expect(http.StatusOK, "GET", "/config/teams", "", "")
+	s.expect(http.StatusOK, "GET", "/metrics/ratelimit", "", "")
+
+	for _, route := range apiRoutes {
+		if pattern := route.Method + " " + API_PREFIX + route.Path; !s.covered[pattern] {
+			t.Errorf("%s was not answered with success", pattern)
+		}
+	}
+}
+
+// TestGameLookupErrors answers a game that can not be loaded with INTERNAL,
+// only missing games are NOT_FOUND.
+func TestGameLookupErrors(t *testing.T) {
+	s := newSpecTest(t)
+	for _, path := range []string{"/games/999999", "/games/999999/position", "/games/999999/record"} {
+		s.expect(http.StatusNotFound, "GET", path, "", "")
+	}
+
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "missing", "app.db"))
+	for _, path := range []string{"/games/1", "/games/1/position", "/games/1/record", "/games/1/events"} {
+		s.expect(http.StatusInternalServerError, "GET", path, "", "")
+	}
+
+	// the replay page is no route of the API
+	req := httptest.NewRequest("GET", "/replay/1", nil)
+	req.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+	serveReplay(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("replay of an unreadable game: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,306 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: arena.proto
+
+package arenapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Arena_SignUp_FullMethodName          = "/rlarena.v1.Arena/SignUp"
+	Arena_GetPlayer_FullMethodName       = "/rlarena.v1.Arena/GetPlayer"
+	Arena_GetGame_FullMethodName         = "/rlarena.v1.Arena/GetGame"
+	Arena_ListActiveGames_FullMethodName = "/rlarena.v1.Arena/ListActiveGames"
+	Arena_SubmitTurn_FullMethodName      = "/rlarena.v1.Arena/SubmitTurn"
+	Arena_Play_FullMethodName            = "/rlarena.v1.Arena/Play"
+)
+
+// ArenaClient is the client API for Arena service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ArenaClient interface {
+	SignUp(ctx context.Context, in *SignUpRequest, opts ...grpc.CallOption) (*SignUpResponse, error)
+	GetPlayer(ctx context.Context, in *GetPlayerRequest, opts ...grpc.CallOption) (*Player, error)
+	GetGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*Game, error)
+	ListActiveGames(ctx context.Context, in *ListActiveGamesRequest, opts ...grpc.CallOption) (*ActiveGames, error)
+	SubmitTurn(ctx context.Context, in *SubmitTurnRequest, opts ...grpc.CallOption) (*Game, error)
+	Play(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PlayRequest, PlayResponse], error)
+}
+
+type arenaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewArenaClient(cc grpc.ClientConnInterface) ArenaClient {
+	return &arenaClient{cc}
+}
+
+func (c *arenaClient) SignUp(ctx context.Context, in *SignUpRequest, opts ...grpc.CallOption) (*SignUpResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignUpResponse)
+	err := c.cc.Invoke(ctx, Arena_SignUp_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *arenaClient) GetPlayer(ctx context.Context, in *GetPlayerRequest, opts ...grpc.CallOption) (*Player, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Player)
+	err := c.cc.Invoke(ctx, Arena_GetPlayer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *arenaClient) GetGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*Game, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Game)
+	err := c.cc.Invoke(ctx, Arena_GetGame_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *arenaClient) ListActiveGames(ctx context.Context, in *ListActiveGamesRequest, opts ...grpc.CallOption) (*ActiveGames, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActiveGames)
+	err := c.cc.Invoke(ctx, Arena_ListActiveGames_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *arenaClient) SubmitTurn(ctx context.Context, in *SubmitTurnRequest, opts ...grpc.CallOption) (*Game, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Game)
+	err := c.cc.Invoke(ctx, Arena_SubmitTurn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *arenaClient) Play(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PlayRequest, PlayResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Arena_ServiceDesc.Streams[0], Arena_Play_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PlayRequest, PlayResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Arena_PlayClient = grpc.BidiStreamingClient[PlayRequest, PlayResponse]
+
+// ArenaServer is the server API for Arena service.
+// All implementations must embed UnimplementedArenaServer
+// for forward compatibility.
+type ArenaServer interface {
+	SignUp(context.Context, *SignUpRequest) (*SignUpResponse, error)
+	GetPlayer(context.Context, *GetPlayerRequest) (*Player, error)
+	GetGame(context.Context, *GetGameRequest) (*Game, error)
+	ListActiveGames(context.Context, *ListActiveGamesRequest) (*ActiveGames, error)
+	SubmitTurn(context.Context, *SubmitTurnRequest) (*Game, error)
+	Play(grpc.BidiStreamingServer[PlayRequest, PlayResponse]) error
+	mustEmbedUnimplementedArenaServer()
+}
+
+// UnimplementedArenaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedArenaServer struct{}
+
+func (UnimplementedArenaServer) SignUp(context.Context, *SignUpRequest) (*SignUpResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignUp not implemented")
+}
+func (UnimplementedArenaServer) GetPlayer(context.Context, *GetPlayerRequest) (*Player, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPlayer not implemented")
+}
+func (UnimplementedArenaServer) GetGame(context.Context, *GetGameRequest) (*Game, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGame not implemented")
+}
+func (UnimplementedArenaServer) ListActiveGames(context.Context, *ListActiveGamesRequest) (*ActiveGames, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListActiveGames not implemented")
+}
+func (UnimplementedArenaServer) SubmitTurn(context.Context, *SubmitTurnRequest) (*Game, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTurn not implemented")
+}
+func (UnimplementedArenaServer) Play(grpc.BidiStreamingServer[PlayRequest, PlayResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Play not implemented")
+}
+func (UnimplementedArenaServer) mustEmbedUnimplementedArenaServer() {}
+func (UnimplementedArenaServer) testEmbeddedByValue()               {}
+
+// UnsafeArenaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ArenaServer will
+// result in compilation errors.
+type UnsafeArenaServer interface {
+	mustEmbedUnimplementedArenaServer()
+}
+
+func RegisterArenaServer(s grpc.ServiceRegistrar, srv ArenaServer) {
+	// If the following call pancis, it indicates UnimplementedArenaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Arena_ServiceDesc, srv)
+}
+
+func _Arena_SignUp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignUpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArenaServer).SignUp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Arena_SignUp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArenaServer).SignUp(ctx, req.(*SignUpRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Arena_GetPlayer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPlayerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArenaServer).GetPlayer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Arena_GetPlayer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArenaServer).GetPlayer(ctx, req.(*GetPlayerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Arena_GetGame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArenaServer).GetGame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Arena_GetGame_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArenaServer).GetGame(ctx, req.(*GetGameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Arena_ListActiveGames_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListActiveGamesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArenaServer).ListActiveGames(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Arena_ListActiveGames_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArenaServer).ListActiveGames(ctx, req.(*ListActiveGamesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Arena_SubmitTurn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTurnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ArenaServer).SubmitTurn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Arena_SubmitTurn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ArenaServer).SubmitTurn(ctx, req.(*SubmitTurnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Arena_Play_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ArenaServer).Play(&grpc.GenericServerStream[PlayRequest, PlayResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Arena_PlayServer = grpc.BidiStreamingServer[PlayRequest, PlayResponse]
+
+// Arena_ServiceDesc is the grpc.ServiceDesc for Arena service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Arena_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rlarena.v1.Arena",
+	HandlerType: (*ArenaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SignUp",
+			Handler:    _Arena_SignUp_Handler,
+		},
+		{
+			MethodName: "GetPlayer",
+			Handler:    _Arena_GetPlayer_Handler,
+		},
+		{
+			MethodName: "GetGame",
+			Handler:    _Arena_GetGame_Handler,
+		},
+		{
+			MethodName: "ListActiveGames",
+			Handler:    _Arena_ListActiveGames_Handler,
+		},
+		{
+			MethodName: "SubmitTurn",
+			Handler:    _Arena_SubmitTurn_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Play",
+			Handler:       _Arena_Play_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "arena.proto",
+}
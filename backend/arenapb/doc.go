@@ -0,0 +1,5 @@
+// Package arenapb holds the protobuf messages and the gRPC service of the
+// arena, generated from arena.proto.
+package arenapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative arena.proto
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Bitboards use a fixed row stride of 16 cells, such that every board up to
+// 16x16 fits into 256 bits. Cell (row, col) is stored in bit row*16 + col.
+const (
+	BITBOARD_MAX_SIZE = 16
+	BITBOARD_STRIDE   = 16
+)
+
+// Mask256 is a 256 bit set. Word 0 holds the bits 0-63.
+type Mask256 [4]uint64
+
+func (m Mask256) And(o Mask256) Mask256 {
+	return Mask256{m[0] & o[0], m[1] & o[1], m[2] & o[2], m[3] & o[3]}
+}
+
+func (m Mask256) Or(o Mask256) Mask256 {
+	return Mask256{m[0] | o[0], m[1] | o[1], m[2] | o[2], m[3] | o[3]}
+}
+
+func (m Mask256) AndNot(o Mask256) Mask256 {
+	return Mask256{m[0] &^ o[0], m[1] &^ o[1], m[2] &^ o[2], m[3] &^ o[3]}
+}
+
+func (m Mask256) IsZero() bool {
+	return m[0]|m[1]|m[2]|m[3] == 0
+}
+
+func (m Mask256) OnesCount() int {
+	return bits.OnesCount64(m[0]) + bits.OnesCount64(m[1]) + bits.OnesCount64(m[2]) + bits.OnesCount64(m[3])
+}
+
+func (m Mask256) Has(idx int) bool {
+	return m[idx>>6]&(1<<(idx&63)) != 0
+}
+
+func (m *Mask256) Set(idx int) {
+	m[idx>>6] |= 1 << (idx & 63)
+}
+
+func (m *Mask256) Clear(idx int) {
+	m[idx>>6] &^= 1 << (idx & 63)
+}
+
+// Shl shifts all bits towards higher indices (0 < n < 64).
+func (m Mask256) Shl(n uint) Mask256 {
+	return Mask256{
+		m[0] << n,
+		m[1]<<n | m[0]>>(64-n),
+		m[2]<<n | m[1]>>(64-n),
+		m[3]<<n | m[2]>>(64-n),
+	}
+}
+
+// Shr shifts all bits towards lower indices (0 < n < 64).
+func (m Mask256) Shr(n uint) Mask256 {
+	return Mask256{
+		m[0]>>n | m[1]<<(64-n),
+		m[1]>>n | m[2]<<(64-n),
+		m[2]>>n | m[3]<<(64-n),
+		m[3] >> n,
+	}
+}
+
+// PopLowest removes the lowest set bit and returns its index (-1 if empty).
+func (m *Mask256) PopLowest() int {
+	for w := 0; w < 4; w++ {
+		if m[w] != 0 {
+			idx := bits.TrailingZeros64(m[w])
+			m[w] &= m[w] - 1
+			return w<<6 + idx
+		}
+	}
+	return -1
+}
+
+// BitMove is a move on a Bitboard. From and To are cell indices.
+type BitMove struct {
+	From    uint8
+	To      uint8
+	Capture bool
+}
+
+// Bitboard is an alternative representation of GameState for fast move
+// generation. It does not keep the move history, only the number of plies.
+type Bitboard struct {
+	Rows  int
+	Cols  int
+	Pawns [2]Mask256 // index 0: player 1, index 1: player 2
+	Ply   int
+	Hash  uint64 // zobrist hash, compatible with GameState.Hash
+
+	startPly int // plies played before the game history started
+
+	cells    Mask256 // all cells of the board
+	notFirst Mask256 // all cells except column 0
+	notLast  Mask256 // all cells except column Cols-1
+	firstRow Mask256
+	lastRow  Mask256
+}
+
+func bitIndex(row int, col int) int {
+	return row*BITBOARD_STRIDE + col
+}
+
+func NewBitboard(rows int, cols int) (*Bitboard, error) {
+	if rows < 2 || cols < 1 || rows > BITBOARD_MAX_SIZE || cols > BITBOARD_MAX_SIZE {
+		return nil, fmt.Errorf("board size %dx%d not supported by bitboards", rows, cols)
+	}
+
+	b := &Bitboard{Rows: rows, Cols: cols}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			idx := bitIndex(row, col)
+			b.cells.Set(idx)
+			if col != 0 {
+				b.notFirst.Set(idx)
+			}
+			if col != cols-1 {
+				b.notLast.Set(idx)
+			}
+			if row == 0 {
+				b.firstRow.Set(idx)
+			}
+			if row == rows-1 {
+				b.lastRow.Set(idx)
+			}
+		}
+	}
+	return b, nil
+}
+
+// BitboardFromState converts the board and side to move of a GameState.
+// Only the standard rules are supported.
+func BitboardFromState(g *GameState) (*Bitboard, error) {
+	if !g.Rules.IsStandard() {
+		return nil, fmt.Errorf("rule variant %q not supported by bitboards", g.Rules.String())
+	}
+	b, err := NewBitboard(g.Rows, g.Cols)
+	if err != nil {
+		return nil, err
+	}
+	for row := 0; row < g.Rows; row++ {
+		for col := 0; col < g.Cols; col++ {
+			switch g.Board[row][col] {
+			case 1:
+				b.Pawns[0].Set(bitIndex(row, col))
+			case 2:
+				b.Pawns[1].Set(bitIndex(row, col))
+			}
+		}
+	}
+	b.startPly = g.startPly
+	b.Ply = g.startPly + len(g.History)
+	b.Hash = zobristHash(g.Board, b.NextPlayer())
+	return b, nil
+}
+
+func (b *Bitboard) NextPlayer() int {
+	return b.Ply%2 + 1
+}
+
+func (b *Bitboard) empty() Mask256 {
+	return b.cells.AndNot(b.Pawns[0].Or(b.Pawns[1]))
+}
+
+// targets returns the destination masks of the side to move for straight
+// moves and captures to the lower and higher column.
+func (b *Bitboard) targets() (straight Mask256, capLeft Mask256, capRight Mask256) {
+	own := b.Pawns[b.Ply%2]
+	opp := b.Pawns[1-b.Ply%2]
+	if b.Ply%2 == 0 {
+		straight = own.Shl(BITBOARD_STRIDE).And(b.empty())
+		capLeft = own.And(b.notFirst).Shl(BITBOARD_STRIDE - 1).And(opp)
+		capRight = own.And(b.notLast).Shl(BITBOARD_STRIDE + 1).And(opp)
+	} else {
+		straight = own.Shr(BITBOARD_STRIDE).And(b.empty())
+		capLeft = own.And(b.notFirst).Shr(BITBOARD_STRIDE + 1).And(opp)
+		capRight = own.And(b.notLast).Shr(BITBOARD_STRIDE - 1).And(opp)
+	}
+	return straight, capLeft, capRight
+}
+
+// GenerateMoves appends all legal moves of the side to move to buf. Passing a
+// buffer with enough capacity avoids allocations.
+func (b *Bitboard) GenerateMoves(buf []BitMove) []BitMove {
+	straight, capLeft, capRight := b.targets()
+
+	// offsets from destination back to source
+	dir := -BITBOARD_STRIDE
+	if b.Ply%2 == 1 {
+		dir = BITBOARD_STRIDE
+	}
+
+	for to := straight.PopLowest(); to >= 0; to = straight.PopLowest() {
+		buf = append(buf, BitMove{From: uint8(to + dir), To: uint8(to)})
+	}
+	for to := capLeft.PopLowest(); to >= 0; to = capLeft.PopLowest() {
+		buf = append(buf, BitMove{From: uint8(to + dir + 1), To: uint8(to), Capture: true})
+	}
+	for to := capRight.PopLowest(); to >= 0; to = capRight.PopLowest() {
+		buf = append(buf, BitMove{From: uint8(to + dir - 1), To: uint8(to), Capture: true})
+	}
+	return buf
+}
+
+func (b *Bitboard) HasMoves() bool {
+	straight, capLeft, capRight := b.targets()
+	return !straight.IsZero() || !capLeft.IsZero() || !capRight.IsZero()
+}
+
+// hashDelta returns the change of the zobrist hash caused by the move of
+// player me (0 or 1).
+func hashDelta(m BitMove, me int) uint64 {
+	from, to := int(m.From), int(m.To)
+	delta := zobristKey(from/BITBOARD_STRIDE, from%BITBOARD_STRIDE, me+1) ^
+		zobristKey(to/BITBOARD_STRIDE, to%BITBOARD_STRIDE, me+1) ^
+		zobristSideKey
+	if m.Capture {
+		delta ^= zobristKey(to/BITBOARD_STRIDE, to%BITBOARD_STRIDE, 2-me)
+	}
+	return delta
+}
+
+// Apply plays the move without checking its legality.
+func (b *Bitboard) Apply(m BitMove) {
+	me := b.Ply % 2
+	b.Pawns[me].Clear(int(m.From))
+	b.Pawns[me].Set(int(m.To))
+	if m.Capture {
+		b.Pawns[1-me].Clear(int(m.To))
+	}
+	b.Hash ^= hashDelta(m, me)
+	b.Ply++
+}
+
+// Undo reverts the last move, which has to be m.
+func (b *Bitboard) Undo(m BitMove) {
+	b.Ply--
+	me := b.Ply % 2
+	b.Pawns[me].Clear(int(m.To))
+	b.Pawns[me].Set(int(m.From))
+	if m.Capture {
+		b.Pawns[1-me].Set(int(m.To))
+	}
+	b.Hash ^= hashDelta(m, me)
+}
+
+// GetWinner follows the semantics of GameState.GetWinner:
+// 1 or 2 for a winner, -1 for a draw and 0 for a running game.
+func (b *Bitboard) GetWinner() int {
+	if !b.Pawns[1].And(b.firstRow).IsZero() {
+		return 2
+	}
+	if !b.Pawns[0].And(b.lastRow).IsZero() {
+		return 1
+	}
+	if !b.HasMoves() {
+		return -1
+	}
+	return 0
+}
+
+func (b *Bitboard) IsEnd() bool {
+	return b.GetWinner() != 0
+}
+
+// ToTurn converts a move into the Turn that would be sent by a client.
+func (b *Bitboard) ToTurn(m BitMove) Turn {
+	return Turn{
+		TurnID:    b.Ply - b.startPly + 1,
+		DestRow:   int(m.To) / BITBOARD_STRIDE,
+		DestCol:   int(m.To) % BITBOARD_STRIDE,
+		SourceRow: int(m.From) / BITBOARD_STRIDE,
+		SourceCol: int(m.From) % BITBOARD_STRIDE,
+		Player:    b.NextPlayer(),
+	}
+}
+
+// FromTurn converts a Turn into a move on this board.
+func (b *Bitboard) FromTurn(t Turn) BitMove {
+	to := bitIndex(t.DestRow, t.DestCol)
+	return BitMove{
+		From:    uint8(bitIndex(t.SourceRow, t.SourceCol)),
+		To:      uint8(to),
+		Capture: t.SourceCol != t.DestCol,
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+var bitboardSizes = [][2]int{{3, 3}, {5, 3}, {4, 7}, {8, 8}, {16, 16}}
+
+func sortTurns(turns []Turn) {
+	sort.Slice(turns, func(i, j int) bool {
+		a, b := turns[i], turns[j]
+		if a.SourceRow != b.SourceRow {
+			return a.SourceRow < b.SourceRow
+		}
+		if a.SourceCol != b.SourceCol {
+			return a.SourceCol < b.SourceCol
+		}
+		if a.DestRow != b.DestRow {
+			return a.DestRow < b.DestRow
+		}
+		return a.DestCol < b.DestCol
+	})
+}
+
+// checkParity compares the moves, the hash and the result of the bitboard
+// with the ones of the game state.
+func checkParity(t *testing.T, g *GameState, b *Bitboard) {
+	t.Helper()
+
+	expected := g.PossibleMoves()
+	actual := make([]Turn, 0, len(expected))
+	for _, m := range b.GenerateMoves(nil) {
+		actual = append(actual, b.ToTurn(m))
+	}
+	if len(expected) != len(actual) {
+		t.Fatalf("%s: bitboard generates %d moves, game state %d", g.Position(), len(actual), len(expected))
+	}
+	sortTurns(expected)
+	sortTurns(actual)
+	for i := range expected {
+		if !expected[i].Eq(actual[i]) || expected[i].TurnID != actual[i].TurnID {
+			t.Fatalf("%s: bitboard move %v differs from game state move %v", g.Position(), actual[i], expected[i])
+		}
+	}
+
+	if b.Hash != g.Hash() {
+		t.Fatalf("%s: bitboard hash %016x differs from game state hash %016x", g.Position(), b.Hash, g.Hash())
+	}
+	if b.GetWinner() != g.GetWinner() {
+		t.Fatalf("%s: bitboard winner %d differs from game state winner %d", g.Position(), b.GetWinner(), g.GetWinner())
+	}
+	if b.IsEnd() != g.IsEnd() {
+		t.Fatalf("%s: bitboard end %v differs from game state end %v", g.Position(), b.IsEnd(), g.IsEnd())
+	}
+	if b.HasMoves() != g.HasMoves() {
+		t.Fatalf("%s: bitboard has moves %v, game state %v", g.Position(), b.HasMoves(), g.HasMoves())
+	}
+}
+
+// TestBitboardParity plays random games on both representations and
+// compares them after every move.
+func TestBitboardParity(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range bitboardSizes {
+		for game := 0; game < 50; game++ {
+			state := NewGameState(size[0], size[1])
+			board, err := BitboardFromState(&state)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for {
+				checkParity(t, &state, board)
+				if state.IsEnd() {
+					break
+				}
+
+				moves := state.PossibleMoves()
+				move := moves[rng.Intn(len(moves))]
+
+				// the move has to be reverted exactly
+				before := *board
+				bitMove := board.FromTurn(move)
+				board.Apply(bitMove)
+				board.Undo(bitMove)
+				if *board != before {
+					t.Fatalf("%s: undo of %v does not restore the bitboard", state.Position(), move)
+				}
+
+				if !state.applyAction(move) {
+					t.Fatalf("%s: move %v rejected", state.Position(), move)
+				}
+				board.Apply(bitMove)
+			}
+		}
+	}
+}
+
+// TestBitboardFromState converts positions in the middle of games instead
+// of following the moves.
+func TestBitboardFromState(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, size := range bitboardSizes {
+		state := randomPosition(rng, size[0], size[1], 1000)
+		board, err := BitboardFromState(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkParity(t, state, board)
+	}
+}
+
+// TestBitboardFromPosition converts custom start positions, with player 2 to
+// move and decided positions, and plays them to the end.
+func TestBitboardFromPosition(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for _, position := range []string{
+		"5x3 ppp/3/3/3/PPP 2",
+		"3x3 p1p/1P1/3 2",
+		"4x3 p2/1P1/1p1/3 1",
+		"3x2 2/2/PP 2",
+		"2x1 p/P 1",
+		"16x16 16/pppppppppppppppp/16/16/16/16/16/16/16/16/16/16/16/16/PPPPPPPPPPPPPPPP/16 2",
+	} {
+		state, err := ParsePosition(position)
+		if err != nil {
+			t.Fatalf("%s: %v", position, err)
+		}
+		board, err := BitboardFromState(state)
+		if err != nil {
+			t.Fatalf("%s: %v", position, err)
+		}
+		for {
+			checkParity(t, state, board)
+			if state.IsEnd() {
+				break
+			}
+			moves := state.PossibleMoves()
+			move := moves[rng.Intn(len(moves))]
+			board.Apply(board.FromTurn(move))
+			state.applyAction(move)
+		}
+	}
+}
+
+// TestBitboardFromStateUnsupported rejects the rule variants and boards
+// above BITBOARD_MAX_SIZE instead of playing by other rules.
+func TestBitboardFromStateUnsupported(t *testing.T) {
+	for _, rules := range []RuleVariant{
+		{DoubleStep: true},
+		{EnPassant: true},
+		{WinOnNoPawns: true},
+		{StalemateLoses: true},
+		{DoubleStep: true, EnPassant: true},
+	} {
+		state := NewGameState(5, 3)
+		state.Rules = rules
+		if _, err := BitboardFromState(&state); err == nil {
+			t.Errorf("rule variant %s was accepted", rules)
+		}
+	}
+
+	for _, size := range [][2]int{{BITBOARD_MAX_SIZE + 1, 8}, {8, BITBOARD_MAX_SIZE + 1}} {
+		state := NewGameState(size[0], size[1])
+		if _, err := BitboardFromState(&state); err == nil {
+			t.Errorf("%dx%d board was accepted", size[0], size[1])
+		}
+	}
+}
+
+// TestSolveBitboardParity compares the search of the adjudication on both
+// representations.
+func TestSolveBitboardParity(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, size := range [][2]int{{3, 3}, {5, 3}, {4, 4}} {
+		for game := 0; game < 30; game++ {
+			state := randomPosition(rng, size[0], size[1], rng.Intn(8))
+			board, err := BitboardFromState(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for depth := 1; depth <= 4; depth++ {
+				a := NewAdjudicator(0)
+				expected := a.solve(state.Clone(), depth)
+				a = NewAdjudicator(0)
+				actual := a.solveBitboard(board, depth)
+				if expected != actual {
+					t.Fatalf("%s depth %d: bitboard search %d, game state search %d", state.Position(), depth, actual, expected)
+				}
+			}
+		}
+	}
+}
+
+// randomPosition plays up to plies random moves, it stops before the game
+// ends.
+func randomPosition(rng *rand.Rand, rows int, cols int, plies int) *GameState {
+	state := NewGameState(rows, cols)
+	for i := 0; i < plies; i++ {
+		moves := state.PossibleMoves()
+		move := moves[rng.Intn(len(moves))]
+		next := state.Clone()
+		next.applyAction(move)
+		if next.IsEnd() {
+			break
+		}
+		state = *next
+	}
+	return &state
+}
+
+func benchmarkPositions(size [2]int) []*GameState {
+	rng := rand.New(rand.NewSource(4))
+	positions := make([]*GameState, 64)
+	for i := range positions {
+		positions[i] = randomPosition(rng, size[0], size[1], size[0])
+	}
+	return positions
+}
+
+func BenchmarkMoveGeneration(b *testing.B) {
+	for _, size := range [][2]int{{8, 8}, {16, 16}} {
+		positions := benchmarkPositions(size)
+		name := fmt.Sprintf("%dx%d", size[0], size[1])
+
+		b.Run("GameState/"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				g := positions[i%len(positions)]
+				it := g.Moves()
+				for _, ok := it.Next(); ok; _, ok = it.Next() {
+				}
+				g.GetWinner()
+			}
+		})
+
+		boards := make([]*Bitboard, len(positions))
+		for i, g := range positions {
+			boards[i], _ = BitboardFromState(g)
+		}
+		buf := make([]BitMove, 0, 3*BITBOARD_MAX_SIZE)
+		b.Run("Bitboard/"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				board := boards[i%len(boards)]
+				buf = board.GenerateMoves(buf[:0])
+				board.GetWinner()
+			}
+		})
+	}
+}
+
+// BenchmarkPlayout plays random games to the end with make and unmake of
+// every move, like the search of the adjudication.
+func BenchmarkPlayout(b *testing.B) {
+	for _, size := range [][2]int{{8, 8}, {16, 16}} {
+		name := fmt.Sprintf("%dx%d", size[0], size[1])
+
+		b.Run("GameState/"+name, func(b *testing.B) {
+			rng := rand.New(rand.NewSource(5))
+			moves := make([]Turn, 0, 3*BITBOARD_MAX_SIZE)
+			for i := 0; i < b.N; i++ {
+				g := NewGameState(size[0], size[1])
+				for g.GetWinner() == 0 {
+					moves = moves[:0]
+					it := g.Moves()
+					for move, ok := it.Next(); ok; move, ok = it.Next() {
+						moves = append(moves, move)
+					}
+					delta := g.MakeMove(moves[rng.Intn(len(moves))])
+					g.UnmakeMove(delta)
+					g.MakeMove(delta.Turn)
+				}
+			}
+		})
+
+		b.Run("Bitboard/"+name, func(b *testing.B) {
+			rng := rand.New(rand.NewSource(5))
+			moves := make([]BitMove, 0, 3*BITBOARD_MAX_SIZE)
+			for i := 0; i < b.N; i++ {
+				board, _ := NewBitboard(size[0], size[1])
+				for col := 0; col < size[1]; col++ {
+					board.Pawns[0].Set(bitIndex(0, col))
+					board.Pawns[1].Set(bitIndex(size[0]-1, col))
+				}
+				for board.GetWinner() == 0 {
+					moves = board.GenerateMoves(moves[:0])
+					move := moves[rng.Intn(len(moves))]
+					board.Apply(move)
+					board.Undo(move)
+					board.Apply(move)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAdjudicate(b *testing.B) {
+	positions := benchmarkPositions([2]int{8, 8})
+
+	b.Run("GameState", func(b *testing.B) {
+		a := NewAdjudicator(0)
+		for i := 0; i < b.N; i++ {
+			state := positions[i%len(positions)].Clone()
+			a.table.Clear()
+			a.nodes = 0
+			a.solve(state, 6)
+		}
+	})
+
+	b.Run("Bitboard", func(b *testing.B) {
+		a := NewAdjudicator(0)
+		for i := 0; i < b.N; i++ {
+			board, _ := BitboardFromState(positions[i%len(positions)])
+			a.table.Clear()
+			a.nodes = 0
+			a.solveBitboard(board, 6)
+		}
+	})
+}
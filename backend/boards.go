@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// limits of configurable board sizes
+const (
+	BOARD_MIN_ROWS = 3
+	BOARD_MAX_ROWS = MAX_NOTATION_SIZE
+	BOARD_MIN_COLS = 1
+	BOARD_MAX_COLS = MAX_NOTATION_SIZE
+)
+
+type BoardShape struct {
+	Rows    int  `json:"rows"`
+	Cols    int  `json:"cols"`
+	Weight  int  `json:"weight"`
+	Enabled bool `json:"enabled"`
+}
+
+// UnmarshalJSON enables shapes unless "enabled": false is given explicitly
+func (s *BoardShape) UnmarshalJSON(data []byte) error {
+	type Alias BoardShape
+	shape := Alias{Weight: 1, Enabled: true}
+	err := json.Unmarshal(data, &shape)
+	if err != nil {
+		return err
+	}
+	*s = BoardShape(shape)
+	return nil
+}
+
+// BoardCatalog is the weighted list of board shapes new games are drawn from.
+type BoardCatalog struct {
+	mutex  sync.RWMutex
+	shapes []BoardShape
+}
+
+var boardCatalog = &BoardCatalog{shapes: DEFAULT_BOARD_SHAPES}
+
+var DEFAULT_BOARD_SHAPES = []BoardShape{
+	{Rows: 3, Cols: 3, Weight: 1, Enabled: true},
+	{Rows: 5, Cols: 3, Weight: 1, Enabled: true},
+	{Rows: 8, Cols: 8, Weight: 1, Enabled: true},
+	{Rows: 16, Cols: 16, Weight: 1, Enabled: true},
+}
+
+func validateBoardShapes(shapes []BoardShape) error {
+	total := 0
+	for _, shape := range shapes {
+		if shape.Rows < BOARD_MIN_ROWS || shape.Rows > BOARD_MAX_ROWS || shape.Cols < BOARD_MIN_COLS || shape.Cols > BOARD_MAX_COLS {
+			return fmt.Errorf("board %dx%d out of range (rows %d-%d, cols %d-%d)", shape.Rows, shape.Cols, BOARD_MIN_ROWS, BOARD_MAX_ROWS, BOARD_MIN_COLS, BOARD_MAX_COLS)
+		}
+		if shape.Weight < 0 {
+			return fmt.Errorf("board %dx%d has a negative weight", shape.Rows, shape.Cols)
+		}
+		if shape.Enabled {
+			total += shape.Weight
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("no enabled board with a positive weight")
+	}
+	return nil
+}
+
+func (c *BoardCatalog) Set(shapes []BoardShape) error {
+	err := validateBoardShapes(shapes)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.shapes = shapes
+	return nil
+}
+
+func (c *BoardCatalog) Shapes() []BoardShape {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return append([]BoardShape{}, c.shapes...)
+}
+
+// Pick draws the size of a new game according to the weights.
+func (c *BoardCatalog) Pick() (int, int) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	total := 0
+	for _, shape := range c.shapes {
+		if shape.Enabled {
+			total += shape.Weight
+		}
+	}
+
+	n := rand.Intn(total)
+	for _, shape := range c.shapes {
+		if !shape.Enabled {
+			continue
+		}
+		if n < shape.Weight {
+			return shape.Rows, shape.Cols
+		}
+		n -= shape.Weight
+	}
+	// unreachable, Set ensures a positive total weight
+	return c.shapes[0].Rows, c.shapes[0].Cols
+}
+
+// loadBoardCatalog reads the board shapes from the JSON file given by the
+// BOARD_CONFIG environment variable, e.g.
+//
+//	[
+//	    {"rows": 3, "cols": 3, "weight": 1},
+//	    {"rows": 8, "cols": 8, "weight": 3},
+//	    {"rows": 16, "cols": 16, "weight": 1, "enabled": false}
+//	]
+//
+// Without BOARD_CONFIG the default shapes are used.
+func loadBoardCatalog() {
+	path := os.Getenv("BOARD_CONFIG")
+	if path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error("Error reading board config, using the default boards", "path", path, "error", err)
+		return
+	}
+
+	var shapes []BoardShape
+	err = json.Unmarshal(data, &shapes)
+	if err == nil {
+		err = boardCatalog.Set(shapes)
+	}
+	if err != nil {
+		slog.Error("Invalid board config, using the default boards", "path", path, "error", err)
+		return
+	}
+	slog.Info("Board config loaded", "path", path, "boards", len(shapes))
+}
+
+// BoardInfo is a board shape with the probability of new games to use it.
+type BoardInfo struct {
+	BoardShape
+	Probability float64 `json:"probability"`
+}
+
+func serveBoardConfig(w http.ResponseWriter, _ *http.Request) {
+	shapes := boardCatalog.Shapes()
+
+	total := 0
+	for _, shape := range shapes {
+		if shape.Enabled {
+			total += shape.Weight
+		}
+	}
+
+	boards := make([]BoardInfo, 0, len(shapes))
+	for _, shape := range shapes {
+		info := BoardInfo{BoardShape: shape}
+		if shape.Enabled && total > 0 {
+			info.Probability = float64(shape.Weight) / float64(total)
+		}
+		boards = append(boards, info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(boards)
+}
+
+func InitHttpHandler_Config() {
+	mux.HandleFunc("GET /config/boards", deprecated("/config/boards", serveBoardConfig))
+}
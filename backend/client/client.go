@@ -0,0 +1,278 @@
+// Package client is the Go client of the arena API and a runner for bots,
+// the counterpart of client/gameClient.py:
+//
+//	c := client.New("http://127.0.0.1:8081")
+//	_, err := c.SignUp(ctx, "myBot") // or c.Token = "<token>"
+//	...
+//	runner := client.NewRunner(c, client.RandomStrategy{})
+//	err = runner.Run(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API_PREFIX is the path of the versioned API below the base URL.
+const API_PREFIX = "/api/v1"
+
+const (
+	DEFAULT_MAX_RETRIES = 5
+	DEFAULT_MIN_BACKOFF = 250 * time.Millisecond
+	DEFAULT_MAX_BACKOFF = 10 * time.Second
+)
+
+// Client sends the requests of one bot. It is safe for concurrent use as
+// long as its fields are not changed meanwhile.
+type Client struct {
+	BaseURL    string // e.g. "https://rlarena.akbakas.de", without API_PREFIX
+	Token      string // token of the bot, set by SignUp
+	HTTPClient *http.Client
+
+	// Failed requests are retried with exponential backoff: GAME_CHANGED,
+	// and network and server errors of GET, PUT and DELETE requests. POST
+	// requests may have been applied despite the error and are only retried
+	// if the connection could not be opened. Rate limited requests wait as
+	// long as the server asks for and do not count as retry.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: DEFAULT_MAX_RETRIES,
+		MinBackoff: DEFAULT_MIN_BACKOFF,
+		MaxBackoff: DEFAULT_MAX_BACKOFF,
+	}
+}
+
+// backoff is the time to wait before the retry, doubled for every attempt
+// with up to 50% jitter.
+func backoff(min time.Duration, max time.Duration, attempt int) time.Duration {
+	wait := time.Duration(float64(min) * math.Pow(2, float64(attempt)))
+	if wait > max || wait <= 0 {
+		wait = max
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do sends the request and decodes the JSON response into result (nil to
+// ignore it). Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method string, path string, body any, result any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; {
+		err := c.send(ctx, method, path, payload, result)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var wait time.Duration
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.Code == ErrRateLimited {
+			wait = retryAfter(apiErr)
+		} else if retryable(method, err) && attempt < c.MaxRetries {
+			wait = backoff(c.MinBackoff, c.MaxBackoff, attempt)
+			attempt++
+		} else {
+			return err
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// retryAfter is the time the server asks to wait after RATE_LIMITED.
+func retryAfter(err *Error) time.Duration {
+	if seconds, ok := err.Details["retry_after"].(float64); ok && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return time.Second
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, payload []byte, result any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+API_PREFIX+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseError(resp)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// responseError reads the error of the response. Responses that are no
+// JSON errors, e.g. of a proxy, get a code by their status.
+func responseError(resp *http.Response) error {
+	apiErr := &Error{Status: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Unmarshal(data, apiErr) == nil && apiErr.Code != "" {
+		return apiErr
+	}
+
+	apiErr.Message = strings.TrimSpace(string(data))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		apiErr.Code = ErrRateLimited
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.Details = map[string]any{"retry_after": float64(seconds)}
+		}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		apiErr.Code = ErrInternal
+	default:
+		apiErr.Code = ErrBadRequest
+	}
+	return apiErr
+}
+
+// ------------------------------
+// Players
+
+// SignUp creates a bot and uses its token for the following requests. The
+// token is shown only once, store it to play with the bot again.
+func (c *Client) SignUp(ctx context.Context, name string) (*NewBot, error) {
+	var bot NewBot
+	err := c.do(ctx, http.MethodPost, "/players", map[string]string{"name": name}, &bot)
+	if err != nil {
+		return nil, err
+	}
+	c.Token = bot.Token
+	return &bot, nil
+}
+
+// Me returns the bot of the token.
+func (c *Client) Me(ctx context.Context) (*Player, error) {
+	var player Player
+	err := c.do(ctx, http.MethodGet, "/players/me", nil, &player)
+	if err != nil {
+		return nil, err
+	}
+	return &player, nil
+}
+
+// SetVersion declares the version the bot plays with, every version has its
+// own rating.
+func (c *Client) SetVersion(ctx context.Context, version string) (*PlayerVersion, error) {
+	var result PlayerVersion
+	err := c.do(ctx, http.MethodPut, "/players/me/version", map[string]string{"version": version}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ------------------------------
+// Games
+
+// ActiveGames returns the running games of the bot.
+func (c *Client) ActiveGames(ctx context.Context) (*ActiveGames, error) {
+	var games ActiveGames
+	err := c.do(ctx, http.MethodGet, "/players/me/games", nil, &games)
+	if err != nil {
+		return nil, err
+	}
+	return &games, nil
+}
+
+func (c *Client) Game(ctx context.Context, gameID int) (*Game, error) {
+	var game Game
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/games/%d", gameID), nil, &game)
+	if err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+// SubmitAction plays the move in the game.
+func (c *Client) SubmitAction(ctx context.Context, gameID int, action Turn) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/games/%d/actions", gameID), action, nil)
+}
+
+// SubmitActions plays in several games with one request. Every action is
+// applied on its own, the errors of the failed ones are in the result. Every
+// game may appear only once.
+func (c *Client) SubmitActions(ctx context.Context, actions []Action) (*ActionResult, error) {
+	var result ActionResult
+	err := c.do(ctx, http.MethodPost, "/games/actions", actions, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Resign(ctx context.Context, gameID int) (*Game, error) {
+	return c.gameAction(ctx, http.MethodPost, fmt.Sprintf("/games/%d/resign", gameID))
+}
+
+// OfferDraw offers a draw, or accepts the offer of the opponent.
+func (c *Client) OfferDraw(ctx context.Context, gameID int) (*Game, error) {
+	return c.gameAction(ctx, http.MethodPost, fmt.Sprintf("/games/%d/draw", gameID))
+}
+
+func (c *Client) DeclineDraw(ctx context.Context, gameID int) (*Game, error) {
+	return c.gameAction(ctx, http.MethodDelete, fmt.Sprintf("/games/%d/draw", gameID))
+}
+
+func (c *Client) gameAction(ctx context.Context, method string, path string) (*Game, error) {
+	var game Game
+	err := c.do(ctx, method, path, nil, &game)
+	if err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a client of the server with short backoffs.
+func testClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := New(server.URL)
+	c.Token = "token"
+	c.MinBackoff = time.Millisecond
+	c.MaxBackoff = time.Millisecond
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestRateLimitedRequestWaitsRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			writeJSON(w, http.StatusTooManyRequests, Error{Code: ErrRateLimited, Message: "Too many requests", Details: map[string]any{"retry_after": 0.2}})
+			return
+		}
+		writeJSON(w, http.StatusOK, Player{ID: 7, Name: "bot"})
+	})
+	// waiting for the rate limit is no retry
+	c.MaxRetries = 0
+
+	start := time.Now()
+	player, err := c.Me(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.ID != 7 || requests.Load() != 2 {
+		t.Errorf("player %+v after %d requests", player, requests.Load())
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("waited %v, want the retry_after of 200ms", waited)
+	}
+}
+
+func TestServerErrorsRetryLimit(t *testing.T) {
+	var requests atomic.Int32
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	c.MaxRetries = 3
+
+	_, err := c.Me(context.Background())
+	if !IsCode(err, ErrInternal) {
+		t.Fatalf("error %v, want %s", err, ErrInternal)
+	}
+	if requests.Load() != 4 {
+		t.Errorf("%d requests, want the first one and 3 retries", requests.Load())
+	}
+
+	// a move may have been applied despite the error, it is not sent again
+	requests.Store(0)
+	err = c.SubmitAction(context.Background(), 1, Turn{})
+	if !IsCode(err, ErrInternal) || requests.Load() != 1 {
+		t.Errorf("move sent %d times: %v", requests.Load(), err)
+	}
+}
+
+func TestGameChangedIsRetried(t *testing.T) {
+	var requests atomic.Int32
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			writeJSON(w, http.StatusConflict, Error{Code: ErrGameChanged, Message: "Game changed"})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	if err := c.SubmitAction(context.Background(), 1, Turn{}); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("%d requests, want 2", requests.Load())
+	}
+}
+
+func TestUnsentPostIsRetryable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	c := New(server.URL)
+	c.MaxRetries = 0
+	_, err := c.SignUp(context.Background(), "bot")
+	if err == nil {
+		t.Fatal("sign up at a closed server succeeded")
+	}
+	if !retryable(http.MethodPost, err) {
+		t.Errorf("refused connection is not retryable: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrorCode is the code of an error response, bots branch on it instead of
+// the message.
+type ErrorCode string
+
+const (
+	ErrBadRequest   ErrorCode = "BAD_REQUEST"
+	ErrInvalidJSON  ErrorCode = "INVALID_JSON"
+	ErrInvalidID    ErrorCode = "INVALID_ID"
+	ErrUnauthorized ErrorCode = "UNAUTHORIZED"
+	ErrInvalidToken ErrorCode = "INVALID_TOKEN"
+	ErrForbidden    ErrorCode = "FORBIDDEN"
+	ErrNotYourGame  ErrorCode = "NOT_YOUR_GAME"
+	ErrNotFound     ErrorCode = "NOT_FOUND"
+	ErrNotAllowed   ErrorCode = "NOT_ALLOWED"
+	ErrConflict     ErrorCode = "CONFLICT"
+	ErrNameTaken    ErrorCode = "NAME_TAKEN"
+	ErrBotRetired   ErrorCode = "BOT_RETIRED"
+	ErrNotYourTurn  ErrorCode = "NOT_YOUR_TURN"
+	ErrGameOver     ErrorCode = "GAME_OVER"
+	ErrGameChanged  ErrorCode = "GAME_CHANGED"
+	ErrNoDrawOffer  ErrorCode = "NO_DRAW_OFFER"
+	ErrIllegalMove  ErrorCode = "ILLEGAL_MOVE"
+	ErrRateLimited  ErrorCode = "RATE_LIMITED"
+	ErrInternal     ErrorCode = "INTERNAL"
+)
+
+// Error is an error response of the server.
+type Error struct {
+	Status  int            `json:"-"` // HTTP status, 0 inside an ActionResult
+	Code    ErrorCode      `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// IsCode reports whether err is an error response with the code.
+func IsCode(err error, code ErrorCode) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// retryable reports whether the request may succeed when it is sent again.
+// Rate limits are handled separately, as they tell the time to wait.
+//
+// GAME_CHANGED rejects the request without applying it. After network and
+// server errors a POST may have been applied (a sign up, a move), it is only
+// sent again if it provably did not reach the server.
+func retryable(method string, err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == ErrGameChanged {
+			return true
+		}
+		return apiErr.Status >= http.StatusInternalServerError && idempotent(method)
+	}
+	return idempotent(method) || notSent(err)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// notSent reports whether the connection to the server could not be opened,
+// such that the request was not sent.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Types of the events, see the Server-Sent Events of the API.
+const (
+	EVENT_STATE = "state" // snapshot of the game (Game), first event of a game stream
+	EVENT_MOVE  = "move"  // MoveEvent
+	EVENT_END   = "end"   // GameEndEvent, last event of a game stream
+
+	EVENT_GAME_START = "game_start" // GameStartEvent
+	EVENT_GAME_END   = "game_end"   // GameEndEvent
+	EVENT_RATING     = "rating"     // RatingChange
+)
+
+const EVENT_MAX_SIZE = 1 << 20
+
+type Event struct {
+	ID   uint64 // number of plies for the events of game streams, 0 otherwise
+	Type string
+	Data json.RawMessage
+}
+
+// Decode unmarshals the data of the event, e.g. into a MoveEvent.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// GameEvents streams the state, the moves and the end of a game. The
+// channel is closed when the game ended, the context is done or the
+// connection broke, the error of the stream is sent on the error channel.
+func (c *Client) GameEvents(ctx context.Context, gameID int) (<-chan Event, <-chan error, error) {
+	return c.events(ctx, fmt.Sprintf("/games/%d/events", gameID))
+}
+
+// GlobalEvents streams the starts and ends of all games and the rating
+// changes.
+func (c *Client) GlobalEvents(ctx context.Context) (<-chan Event, <-chan error, error) {
+	return c.events(ctx, "/events")
+}
+
+func (c *Client) events(ctx context.Context, path string) (<-chan Event, <-chan error, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+API_PREFIX+path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, nil, responseError(resp)
+	}
+
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		errs <- readEvents(ctx, bufio.NewScanner(resp.Body), events)
+	}()
+	return events, errs, nil
+}
+
+// readEvents parses the stream until it ends, comments (keepalives) are
+// skipped.
+func readEvents(ctx context.Context, scanner *bufio.Scanner, events chan<- Event) error {
+	scanner.Buffer(make([]byte, 0, 64*1024), EVENT_MAX_SIZE)
+
+	var event Event
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if event.Type == "" && len(data) == 0 {
+				continue
+			}
+			if event.Type == "" {
+				event.Type = "message"
+			}
+			event.Data = json.RawMessage(strings.Join(data, "\n"))
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			event, data = Event{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.ID, _ = strconv.ParseUint(value, 10, 64)
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGameEventsStream(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != API_PREFIX+"/games/3/events" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "id: 1\nevent: move\ndata: {\"game_id\": 3,\ndata:  \"notation\": \"a2-a3\"}\n\n")
+		fmt.Fprint(w, ": keepalive\n")
+		fmt.Fprint(w, "data: plain\n\n")
+		fmt.Fprint(w, "event: end\ndata: {\"game_id\": 3, \"outcome\": 1}\n\n")
+	})
+
+	events, errs, err := c.GameEvents(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received []Event
+	for event := range events {
+		received = append(received, event)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("%d events, want 3: %v", len(received), received)
+	}
+	var move MoveEvent
+	if err := received[0].Decode(&move); err != nil {
+		t.Fatalf("multi-line data %q: %v", received[0].Data, err)
+	}
+	if received[0].ID != 1 || received[0].Type != EVENT_MOVE || move.GameID != 3 || move.Notation != "a2-a3" {
+		t.Errorf("move event %+v decoded as %+v", received[0], move)
+	}
+	if received[1].Type != "message" || string(received[1].Data) != "plain" {
+		t.Errorf("event without type: %+v", received[1])
+	}
+	var end GameEndEvent
+	if err := received[2].Decode(&end); err != nil || received[2].Type != EVENT_END || end.Outcome != 1 {
+		t.Errorf("end event %+v: %v", received[2], err)
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Strategy chooses the move of the bot among the legal moves of the state.
+// The runner calls it for several games at once if its Concurrency is above
+// one, it has to be safe for concurrent use then.
+type Strategy interface {
+	SelectMove(moves []Turn, state *GameState) Turn
+}
+
+// StrategyFunc turns a function into a Strategy.
+type StrategyFunc func(moves []Turn, state *GameState) Turn
+
+func (f StrategyFunc) SelectMove(moves []Turn, state *GameState) Turn {
+	return f(moves, state)
+}
+
+// RandomStrategy plays a random legal move.
+type RandomStrategy struct{}
+
+func (RandomStrategy) SelectMove(moves []Turn, _ *GameState) Turn {
+	return moves[rand.Intn(len(moves))]
+}
+
+const (
+	DEFAULT_POLL_INTERVAL = 500 * time.Millisecond // like SHORT_AWAIT_NEW_GAMES of gameClient.py
+	DEFAULT_IDLE_INTERVAL = 5 * time.Second        // like AWAIT_NEW_GAMES of gameClient.py
+	RUNNER_MAX_BACKOFF    = time.Minute
+)
+
+// Runner plays the games of a bot with its strategy, like Client.play of
+// gameClient.py: it polls the active games, selects the moves of the games
+// where it is its turn and sends them with one request.
+type Runner struct {
+	Client      *Client
+	Strategy    Strategy
+	Concurrency int // games whose moves are selected at the same time
+
+	PollInterval time.Duration // wait between two rounds
+	IdleInterval time.Duration // wait after a failed round, doubled while they keep failing
+	Logger       *slog.Logger
+}
+
+func NewRunner(c *Client, strategy Strategy) *Runner {
+	return &Runner{
+		Client:       c,
+		Strategy:     strategy,
+		Concurrency:  1,
+		PollInterval: DEFAULT_POLL_INTERVAL,
+		IdleInterval: DEFAULT_IDLE_INTERVAL,
+		Logger:       slog.Default(),
+	}
+}
+
+// Run plays until the context is done and returns nil then. It only stops
+// early if the token is not accepted or the bot is retired, other errors are
+// logged and retried with backoff.
+func (r *Runner) Run(ctx context.Context) error {
+	if r.Client.Token == "" {
+		return errors.New("no token, sign up first")
+	}
+
+	failures := 0
+	for ctx.Err() == nil {
+		wait := r.PollInterval
+		err := r.round(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case IsCode(err, ErrUnauthorized), IsCode(err, ErrInvalidToken), IsCode(err, ErrBotRetired):
+			return err
+		case err != nil:
+			wait = backoff(r.IdleInterval, RUNNER_MAX_BACKOFF, failures)
+			failures++
+			r.Logger.Warn("Round failed", "error", err, "failures", failures, "wait", wait)
+		default:
+			failures = 0
+		}
+
+		if sleep(ctx, wait) != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// round plays one move in every game where it is the turn of the bot.
+func (r *Runner) round(ctx context.Context) error {
+	active, err := r.Client.ActiveGames(ctx)
+	if err != nil {
+		return err
+	}
+	r.Logger.Debug("Games found", "my_turn", len(active.MyTurn), "awaiting", len(active.Awaiting))
+	if len(active.MyTurn) == 0 {
+		return nil
+	}
+
+	actions := r.selectMoves(active.MyTurn)
+	if len(actions) == 0 {
+		return nil
+	}
+	result, err := r.Client.SubmitActions(ctx, actions)
+	if err != nil {
+		return err
+	}
+	// the actions of other games are applied even if some fail
+	for gameID, actionErr := range result.Errors {
+		r.Logger.Warn("Action failed", "game", gameID, "code", actionErr.Code, "message", actionErr.Message)
+	}
+	return nil
+}
+
+// selectMoves asks the strategy for the moves of the games, up to
+// Concurrency games at once.
+func (r *Runner) selectMoves(games []Game) []Action {
+	actions := make([]*Action, len(games))
+	slots := make(chan struct{}, max(r.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range games {
+		game := &games[i]
+		if game.GameState == nil || len(game.GameState.MoveOptions) == 0 {
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			move := r.Strategy.SelectMove(game.GameState.MoveOptions, game.GameState)
+			actions[i] = &Action{GameID: game.ID, Action: move}
+		}()
+	}
+	wg.Wait()
+
+	result := make([]Action, 0, len(actions))
+	for _, action := range actions {
+		if action != nil {
+			result = append(result, *action)
+		}
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRunnerRound(t *testing.T) {
+	options := []Turn{{SourceRow: 0, SourceCol: 1, DestRow: 1, DestCol: 1, Player: 1}, {SourceRow: 0, SourceCol: 2, DestRow: 1, DestCol: 2, Player: 1}}
+	active := ActiveGames{
+		MyTurn: []Game{
+			{ID: 1, GameState: &GameState{MoveOptions: options}},
+			{ID: 2, GameState: &GameState{MoveOptions: options[1:]}},
+		},
+		Awaiting: []Game{{ID: 3, GameState: &GameState{MoveOptions: options}}},
+	}
+
+	var submitted []Action
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET " + API_PREFIX + "/players/me/games":
+			writeJSON(w, http.StatusOK, active)
+		case "POST " + API_PREFIX + "/games/actions":
+			if submitted != nil {
+				t.Error("actions were submitted twice")
+			}
+			if err := json.NewDecoder(r.Body).Decode(&submitted); err != nil {
+				t.Error(err)
+			}
+			writeJSON(w, http.StatusOK, ActionResult{Applied: len(submitted), Errors: map[int]*Error{}})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	})
+
+	runner := NewRunner(c, StrategyFunc(func(moves []Turn, _ *GameState) Turn { return moves[0] }))
+	runner.Concurrency = 2
+	if err := runner.round(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Action{{GameID: 1, Action: options[0]}, {GameID: 2, Action: options[1]}}
+	if len(submitted) != len(want) {
+		t.Fatalf("submitted %+v, want one action per game with my turn", submitted)
+	}
+	for i := range want {
+		if submitted[i] != want[i] {
+			t.Errorf("action %d is %+v, want %+v", i, submitted[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package client
+
+// The types mirror the JSON of the API, see /api/v1/openapi.json.
+
+type Player struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	CurrentElo int    `json:"current_elo"`
+	OwnerID    int    `json:"owner_id,omitempty"`
+	Retired    bool   `json:"retired"`
+	TeamID     int    `json:"team_id,omitempty"`
+}
+
+// Turn is a move of a player from the source to the destination square.
+type Turn struct {
+	TurnID    int `json:"turnID"`
+	DestRow   int `json:"destRow"`
+	DestCol   int `json:"destCol"`
+	SourceRow int `json:"sourceRow"`
+	SourceCol int `json:"sourceCol"`
+	Player    int `json:"player"`
+}
+
+type GameState struct {
+	Rows    int     `json:"rows"`
+	Cols    int     `json:"cols"`
+	History []Turn  `json:"history"`
+	Board   [][]int `json:"board"` // 0: empty, 1 or 2: pawn of the player
+
+	GameOver      bool   `json:"gameOver"`
+	Winner        int    `json:"winner"` // -1: draw, 0: ongoing, 1 or 2: player
+	MoveOptions   []Turn `json:"moveOptions"`
+	CurrentPlayer int    `json:"currentPlayer"`
+	Hash          string `json:"hash"`
+	Position      string `json:"position"`
+	Start         string `json:"start,omitempty"`
+}
+
+type Game struct {
+	ID          int        `json:"id"`
+	Player1ID   int        `json:"player1_id"`
+	Player2ID   int        `json:"player2_id"`
+	Outcome     int        `json:"outcome"`      // -1: draw, 0: ongoing, 1: win player 1, 2: win player 2
+	CreatedAt   int64      `json:"created_at"`   // unix seconds
+	EndedAt     int64      `json:"ended_at"`     // unix seconds, 0 while the game is running
+	Termination string     `json:"termination"`  // "" while the game is running
+	DrawOffer   int        `json:"draw_offer"`   // player with a pending draw offer, 0 for none
+	LastMoveAt  int64      `json:"last_move_at"` // unix seconds, 0 before the first move
+	Unrated     bool       `json:"unrated"`      // games from custom positions do not change the ratings
+	GameState   *GameState `json:"game_state"`
+}
+
+// ActiveGames are the running games of a player, split by the player to move.
+type ActiveGames struct {
+	MyTurn   []Game `json:"my_turn"`
+	Awaiting []Game `json:"awaiting"`
+}
+
+type NewBot struct {
+	ID    int    `json:"id"`
+	Token string `json:"token"`
+}
+
+type PlayerVersion struct {
+	ID        int    `json:"id"`
+	Version   string `json:"version"`
+	Elo       int    `json:"elo"`
+	SeedElo   int    `json:"seed_elo"` // rating the version started with
+	CreatedAt int64  `json:"created_at"`
+	Current   bool   `json:"current"`
+}
+
+// Action is a move in one of several games, see Client.SubmitActions.
+type Action struct {
+	GameID int  `json:"gameId"`
+	Action Turn `json:"action"`
+}
+
+// ActionResult is the answer to Client.SubmitActions: the number of applied
+// actions and the errors of the others by game id.
+type ActionResult struct {
+	Applied int            `json:"applied"`
+	Errors  map[int]*Error `json:"errors"`
+}
+
+type MoveEvent struct {
+	GameID        int    `json:"game_id"`
+	Turn          Turn   `json:"turn"`
+	Notation      string `json:"notation"`
+	Position      string `json:"position"`
+	CurrentPlayer int    `json:"current_player"`
+}
+
+type GameEndEvent struct {
+	GameID      int    `json:"game_id"`
+	Player1ID   int    `json:"player1_id"`
+	Player2ID   int    `json:"player2_id"`
+	Outcome     int    `json:"outcome"`
+	Termination string `json:"termination"`
+}
+
+type GameStartEvent struct {
+	GameID    int    `json:"game_id"`
+	Player1ID int    `json:"player1_id"`
+	Player2ID int    `json:"player2_id"`
+	Rows      int    `json:"rows"`
+	Cols      int    `json:"cols"`
+	Rules     string `json:"rules"`
+}
+
+type RatingChange struct {
+	GameID    int `json:"game_id"`
+	PlayerID  int `json:"player_id"`
+	EloBefore int `json:"elo_before"`
+	EloAfter  int `json:"elo_after"`
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrorCode tells clients what went wrong, such that bots can branch on it
+// instead of parsing the message. Every code belongs to one HTTP status.
+type ErrorCode string
+
+const (
+	ERR_BAD_REQUEST   ErrorCode = "BAD_REQUEST"   // 400: a parameter or the body is invalid
+	ERR_INVALID_JSON  ErrorCode = "INVALID_JSON"  // 400: the body is no valid JSON
+	ERR_INVALID_ID    ErrorCode = "INVALID_ID"    // 400: an id in the path is no number
+	ERR_UNAUTHORIZED  ErrorCode = "UNAUTHORIZED"  // 401: a token, session or login is missing or wrong
+	ERR_INVALID_TOKEN ErrorCode = "INVALID_TOKEN" // 401: the token is unknown or revoked
+	ERR_FORBIDDEN     ErrorCode = "FORBIDDEN"     // 403: the resource belongs to someone else
+	ERR_NOT_YOUR_GAME ErrorCode = "NOT_YOUR_GAME" // 403: the player does not take part in the game
+	ERR_NOT_FOUND     ErrorCode = "NOT_FOUND"     // 404: the resource does not exist
+	ERR_NOT_ALLOWED   ErrorCode = "NOT_ALLOWED"   // 405: the path does not support the method
+	ERR_CONFLICT      ErrorCode = "CONFLICT"      // 409: the request contradicts the current state
+	ERR_NAME_TAKEN    ErrorCode = "NAME_TAKEN"    // 409: the name is used already
+	ERR_BOT_RETIRED   ErrorCode = "BOT_RETIRED"   // 409: the bot is retired
+	ERR_NOT_YOUR_TURN ErrorCode = "NOT_YOUR_TURN" // 409: the opponent has to move
+	ERR_GAME_OVER     ErrorCode = "GAME_OVER"     // 409: the game has ended
+	ERR_GAME_CHANGED  ErrorCode = "GAME_CHANGED"  // 409: the game changed meanwhile, retry
+	ERR_NO_DRAW_OFFER ErrorCode = "NO_DRAW_OFFER" // 409: the opponent offers no draw
+	ERR_ILLEGAL_MOVE  ErrorCode = "ILLEGAL_MOVE"  // 422: the move is malformed or against the rules
+	ERR_RATE_LIMITED  ErrorCode = "RATE_LIMITED"  // 429: too many requests, see Retry-After
+	ERR_INTERNAL      ErrorCode = "INTERNAL"      // 500: an error of the server
+)
+
+var errorStatus = map[ErrorCode]int{
+	ERR_BAD_REQUEST:   http.StatusBadRequest,
+	ERR_INVALID_JSON:  http.StatusBadRequest,
+	ERR_INVALID_ID:    http.StatusBadRequest,
+	ERR_UNAUTHORIZED:  http.StatusUnauthorized,
+	ERR_INVALID_TOKEN: http.StatusUnauthorized,
+	ERR_FORBIDDEN:     http.StatusForbidden,
+	ERR_NOT_YOUR_GAME: http.StatusForbidden,
+	ERR_NOT_FOUND:     http.StatusNotFound,
+	ERR_NOT_ALLOWED:   http.StatusMethodNotAllowed,
+	ERR_CONFLICT:      http.StatusConflict,
+	ERR_NAME_TAKEN:    http.StatusConflict,
+	ERR_BOT_RETIRED:   http.StatusConflict,
+	ERR_NOT_YOUR_TURN: http.StatusConflict,
+	ERR_GAME_OVER:     http.StatusConflict,
+	ERR_GAME_CHANGED:  http.StatusConflict,
+	ERR_NO_DRAW_OFFER: http.StatusConflict,
+	ERR_ILLEGAL_MOVE:  http.StatusUnprocessableEntity,
+	ERR_RATE_LIMITED:  http.StatusTooManyRequests,
+	ERR_INTERNAL:      http.StatusInternalServerError,
+}
+
+// Status is the HTTP status of the error code.
+func (code ErrorCode) Status() int {
+	if status, ok := errorStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+var (
+	ErrNotYourTurn = errors.New("not your turn")
+	ErrIllegalMove = errors.New("invalid action (against the rules)")
+)
+
+// APIError is the body of every error response.
+type APIError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Details any       `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// NewAPIError creates an error that is sent to the client as it is.
+func NewAPIError(code ErrorCode, message string) *APIError {
+	return &APIError{Code: code, Message: message}
+}
+
+// apiError converts the errors of the game logic into the error sent to
+// the client. Unknown errors become internal errors without their message.
+func apiError(err error) *APIError {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, sql.ErrNoRows):
+		return NewAPIError(ERR_NOT_FOUND, "Not found")
+	case errors.Is(err, ErrNotYourTurn):
+		return NewAPIError(ERR_NOT_YOUR_TURN, err.Error())
+	case errors.Is(err, ErrIllegalMove):
+		return NewAPIError(ERR_ILLEGAL_MOVE, err.Error())
+	case errors.Is(err, ErrGameOver):
+		return NewAPIError(ERR_GAME_OVER, err.Error())
+	case errors.Is(err, ErrGameChanged):
+		return NewAPIError(ERR_GAME_CHANGED, err.Error())
+	case errors.Is(err, ErrNameTaken):
+		return NewAPIError(ERR_NAME_TAKEN, "Name is already taken")
+	}
+	return NewAPIError(ERR_INTERNAL, "Internal server error")
+}
+
+// writeError sends the error as JSON with the status of its code. It
+// replaces http.Error, the message should be readable by people.
+func writeError(w http.ResponseWriter, code ErrorCode, message string) {
+	writeAPIError(w, NewAPIError(code, message))
+}
+
+func writeErrorDetails(w http.ResponseWriter, code ErrorCode, message string, details any) {
+	writeAPIError(w, &APIError{Code: code, Message: message, Details: details})
+}
+
+func writeAPIError(w http.ResponseWriter, err *APIError) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(err.Code.Status())
+	json.NewEncoder(w).Encode(err)
+}
+
+// patterns that match the requests without a handler, GET / redirects to
+// the leaderboard outside the API
+var unmatchedPatterns = []string{"/", "GET /", "GET " + API_PREFIX + "/"}
+
+// serveUnmatched answers the requests no other pattern of the mux matches,
+// such that these errors are JSON as well.
+func serveUnmatched(w http.ResponseWriter, r *http.Request) {
+	allowed := []string{}
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); !slices.Contains(unmatchedPatterns, pattern) {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) == 0 {
+		writeError(w, ERR_NOT_FOUND, "Not found")
+		return
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, ERR_NOT_ALLOWED, "Method not allowed")
+}
+
+func InitHttpHandler_Errors() {
+	mux.HandleFunc("/", serveUnmatched)
+	mux.HandleFunc("GET "+API_PREFIX+"/", serveUnmatched)
+}
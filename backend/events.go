@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Events are pushed to spectators as Server-Sent Events. Every game has its
+// own stream with the moves and the result, the global stream announces
+// started and finished games and rating changes. The stream of a player tells
+// bots of the gRPC API when it is their turn and when their games end.
+const (
+	EVENT_STATE = "state" // snapshot of the game, first event of a game stream
+	EVENT_MOVE  = "move"
+	EVENT_END   = "end"
+
+	EVENT_GAME_START = "game_start"
+	EVENT_GAME_END   = "game_end"
+	EVENT_RATING     = "rating"
+
+	EVENT_TURN = "turn"
+)
+
+const (
+	EVENT_BUFFER_SIZE = 64 // subscribers that fall further behind are dropped
+	EVENT_KEEPALIVE   = 30 * time.Second
+	GLOBAL_EVENTS     = 0 // topic of the global stream, games use their id
+)
+
+// playerTopic is the topic of the stream of a player, the negative id such
+// that it does not collide with the games.
+func playerTopic(playerID int) int {
+	return -playerID
+}
+
+// Event is a message of a stream. Events of game streams carry the number of
+// plies played as ID, such that a reconnecting client continues after the
+// last move it has seen (Last-Event-ID). Other events have no ID.
+type Event struct {
+	ID   uint64
+	Type string
+	Data any
+}
+
+type MoveEvent struct {
+	GameID        int    `json:"game_id"`
+	Turn          Turn   `json:"turn"`
+	Notation      string `json:"notation"`
+	Position      string `json:"position"`
+	CurrentPlayer int    `json:"current_player"`
+}
+
+type GameEndEvent struct {
+	GameID      int    `json:"game_id"`
+	Player1ID   int    `json:"player1_id"`
+	Player2ID   int    `json:"player2_id"`
+	Outcome     int    `json:"outcome"`
+	Termination string `json:"termination"`
+}
+
+type GameStartEvent struct {
+	GameID    int    `json:"game_id"`
+	Player1ID int    `json:"player1_id"`
+	Player2ID int    `json:"player2_id"`
+	Rows      int    `json:"rows"`
+	Cols      int    `json:"cols"`
+	Rules     string `json:"rules"`
+}
+
+// TurnEvent tells a player that it is their turn, Ply is the number of moves
+// played so far.
+type TurnEvent struct {
+	GameID int `json:"game_id"`
+	Ply    int `json:"ply"`
+}
+
+type RatingChange struct {
+	GameID    int `json:"game_id"`
+	PlayerID  int `json:"player_id"`
+	EloBefore int `json:"elo_before"`
+	EloAfter  int `json:"elo_after"`
+}
+
+// EventHub distributes events to the subscribers of a topic. Publishing never
+// blocks, slow subscribers are disconnected instead.
+type EventHub struct {
+	mutex       sync.Mutex
+	subscribers map[int]map[chan Event]bool
+}
+
+var events = NewEventHub()
+
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: make(map[int]map[chan Event]bool)}
+}
+
+func (h *EventHub) Subscribe(topic int) chan Event {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	ch := make(chan Event, EVENT_BUFFER_SIZE)
+	if h.subscribers[topic] == nil {
+		h.subscribers[topic] = make(map[chan Event]bool)
+	}
+	h.subscribers[topic][ch] = true
+	return ch
+}
+
+func (h *EventHub) Unsubscribe(topic int, ch chan Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.remove(topic, ch)
+}
+
+// remove closes the channel, the mutex has to be held
+func (h *EventHub) remove(topic int, ch chan Event) {
+	if !h.subscribers[topic][ch] {
+		return
+	}
+	delete(h.subscribers[topic], ch)
+	if len(h.subscribers[topic]) == 0 {
+		delete(h.subscribers, topic)
+	}
+	close(ch)
+}
+
+func (h *EventHub) Publish(topic int, eventType string, data any) {
+	h.publishEvent(topic, Event{Type: eventType, Data: data})
+}
+
+func (h *EventHub) publishEvent(topic int, event Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for ch := range h.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Dropping slow event subscriber", "topic", topic)
+			h.remove(topic, ch)
+		}
+	}
+}
+
+func (h *EventHub) publishGameStart(game *Game) {
+	h.Publish(GLOBAL_EVENTS, EVENT_GAME_START, GameStartEvent{
+		GameID:    game.ID,
+		Player1ID: game.Player1ID,
+		Player2ID: game.Player2ID,
+		Rows:      game.GameState.Rows,
+		Cols:      game.GameState.Cols,
+		Rules:     game.GameState.Rules.String(),
+	})
+	h.publishTurn(game)
+}
+
+// publishTurn tells the player to move in a running game.
+func (h *EventHub) publishTurn(game *Game) {
+	if game.Outcome != 0 {
+		return
+	}
+	next := game.Player1ID
+	if game.GameState.NextPlayer() == 2 {
+		next = game.Player2ID
+	}
+	h.Publish(playerTopic(next), EVENT_TURN, TurnEvent{GameID: game.ID, Ply: len(game.GameState.History)})
+}
+
+func (h *EventHub) publishMove(game *Game, action Turn) {
+	h.publishEvent(game.ID, moveEvent(game.ID, len(game.GameState.History), action, game.GameState))
+	h.publishTurn(game)
+}
+
+// moveEvent describes the move that led to the state, the ply-th of the game.
+func moveEvent(gameID int, ply int, action Turn, state *GameState) Event {
+	return Event{ID: uint64(ply), Type: EVENT_MOVE, Data: MoveEvent{
+		GameID:        gameID,
+		Turn:          action,
+		Notation:      action.Notation(),
+		Position:      state.Position(),
+		CurrentPlayer: state.NextPlayer(),
+	}}
+}
+
+// missedMoves replays the game and returns the events of the moves after the
+// given ply.
+func missedMoves(game *Game, ply int) ([]Event, error) {
+	state, err := initialGameState(game.GameState.Rows, game.GameState.Cols, game.GameState.Rules, game.GameState.StartPosition())
+	if err != nil {
+		return nil, err
+	}
+	missed := make([]Event, 0, len(game.GameState.History)-ply)
+	for i, turn := range game.GameState.History {
+		if !state.applyAction(turn) {
+			return nil, fmt.Errorf("invalid turn %v in the history of game %d", turn, game.ID)
+		}
+		if i+1 > ply {
+			missed = append(missed, moveEvent(game.ID, i+1, turn, state))
+		}
+	}
+	return missed, nil
+}
+
+func gameEndEvent(game *Game) GameEndEvent {
+	return GameEndEvent{
+		GameID:      game.ID,
+		Player1ID:   game.Player1ID,
+		Player2ID:   game.Player2ID,
+		Outcome:     game.Outcome,
+		Termination: game.Termination,
+	}
+}
+
+func (h *EventHub) publishGameEnd(game *Game) {
+	end := gameEndEvent(game)
+	h.publishEvent(game.ID, Event{ID: uint64(len(game.GameState.History)), Type: EVENT_END, Data: end})
+	h.Publish(GLOBAL_EVENTS, EVENT_GAME_END, end)
+	h.Publish(playerTopic(game.Player1ID), EVENT_END, end)
+	h.Publish(playerTopic(game.Player2ID), EVENT_END, end)
+}
+
+func writeEvent(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if event.ID != 0 {
+		_, err = fmt.Fprintf(w, "id: %d\n", event.ID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	if err != nil {
+		return err
+	}
+	w.(http.Flusher).Flush()
+	return nil
+}
+
+// streamEvents writes the first events and then forwards the events of the
+// channel until the client disconnects or the game ends. Events for which
+// skip returns true are not forwarded.
+func streamEvents(w http.ResponseWriter, r *http.Request, ch chan Event, skip func(Event) bool, first ...Event) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	// clients wait for the headers before reading the stream
+	w.(http.Flusher).Flush()
+
+	for _, event := range first {
+		if writeEvent(w, event) != nil {
+			return
+		}
+	}
+	if ch == nil {
+		return
+	}
+
+	keepalive := time.NewTicker(EVENT_KEEPALIVE)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				// dropped as slow subscriber
+				return
+			}
+			if skip != nil && skip(event) {
+				continue
+			}
+			if writeEvent(w, event) != nil {
+				return
+			}
+			if event.Type == EVENT_END {
+				return
+			}
+		case <-keepalive.C:
+			_, err := fmt.Fprint(w, ": keepalive\n\n")
+			if err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func serveGameEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, ERR_INTERNAL, "Streaming not supported")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
+
+	// subscribe before reading the snapshot, such that no move is missed
+	ch := events.Subscribe(id)
+	defer events.Unsubscribe(id, ch)
+
+	game, err := getGame(id)
+	if err != nil {
+		writeGameError(w, id, err)
+		return
+	}
+	plies := len(game.GameState.History)
+
+	// a reconnecting client only gets the moves it missed, otherwise the
+	// stream starts with a snapshot
+	first := []Event{{ID: uint64(plies), Type: EVENT_STATE, Data: game}}
+	if lastID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && lastID >= 0 && lastID <= plies {
+		first, err = missedMoves(game, lastID)
+		if err != nil {
+			slog.Error("Error replaying game for event stream", "id", id, "error", err)
+			writeError(w, ERR_INTERNAL, "Error replaying game")
+			return
+		}
+	}
+
+	if game.Outcome != 0 {
+		end := Event{ID: uint64(plies), Type: EVENT_END, Data: gameEndEvent(game)}
+		streamEvents(w, r, nil, nil, append(first, end)...)
+		return
+	}
+	// moves published between the subscription and the snapshot are part of it
+	skip := func(event Event) bool {
+		return event.Type == EVENT_MOVE && event.ID <= uint64(plies)
+	}
+	streamEvents(w, r, ch, skip, first...)
+}
+
+func serveGlobalEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, ERR_INTERNAL, "Streaming not supported")
+		return
+	}
+
+	ch := events.Subscribe(GLOBAL_EVENTS)
+	defer events.Unsubscribe(GLOBAL_EVENTS, ch)
+	streamEvents(w, r, ch, nil)
+}
+
+func InitHttpHandler_Events() {
+	mux.HandleFunc("GET /game/{id}/events", deprecated("/games/{id}/events", serveGameEvents))
+	mux.HandleFunc("GET /events", deprecated("/events", serveGlobalEvents))
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// GameCache keeps all active games in memory. Every write goes to the
+// database first and only afterwards to the cache (write-through), so the
+// database always stays the source of truth.
+type GameCache struct {
+	mutex    sync.RWMutex
+	loaded   bool
+	games    map[int]*Game
+	byPlayer map[int]map[int]bool // player id -> set of game ids
+}
+
+var activeGames = NewGameCache()
+
+func NewGameCache() *GameCache {
+	return &GameCache{
+		games:    make(map[int]*Game),
+		byPlayer: make(map[int]map[int]bool),
+	}
+}
+
+// copyGame returns a deep copy, such that callers can not modify the cached
+// state by accident.
+func copyGame(game *Game) *Game {
+	cpy := *game
+	cpy.GameState = game.GameState.Clone()
+	return &cpy
+}
+
+// Load replaces the content of the cache with the active games of the database.
+func (c *GameCache) Load() error {
+	games, err := DB_Get_Active_Games()
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.games = make(map[int]*Game)
+	c.byPlayer = make(map[int]map[int]bool)
+	for i := range games {
+		c.put(&games[i])
+	}
+	c.loaded = true
+
+	slog.Info("Active game cache loaded", "games", len(c.games))
+	return nil
+}
+
+func (c *GameCache) Loaded() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.loaded
+}
+
+func (c *GameCache) put(game *Game) {
+	c.games[game.ID] = game
+	for _, playerID := range []int{game.Player1ID, game.Player2ID} {
+		if c.byPlayer[playerID] == nil {
+			c.byPlayer[playerID] = make(map[int]bool)
+		}
+		c.byPlayer[playerID][game.ID] = true
+	}
+}
+
+func (c *GameCache) evict(id int) {
+	game, ok := c.games[id]
+	if !ok {
+		return
+	}
+	delete(c.games, id)
+	for _, playerID := range []int{game.Player1ID, game.Player2ID} {
+		delete(c.byPlayer[playerID], id)
+		if len(c.byPlayer[playerID]) == 0 {
+			delete(c.byPlayer, playerID)
+		}
+	}
+}
+
+// Update stores the game if it is still running and evicts it otherwise.
+// It has to be called after the game has been persisted. A running game with
+// fewer plies than the cached one is outdated and ignored.
+func (c *GameCache) Update(game *Game) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.loaded {
+		return
+	}
+
+	if game.Outcome != 0 {
+		c.evict(game.ID)
+		return
+	}
+	if cached, ok := c.games[game.ID]; ok && len(cached.GameState.History) > len(game.GameState.History) {
+		slog.Warn("Ignoring outdated game in cache update", "id", game.ID,
+			"plies", len(game.GameState.History), "cached", len(cached.GameState.History))
+		return
+	}
+	c.put(copyGame(game))
+}
+
+func (c *GameCache) Evict(id int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.evict(id)
+}
+
+// Get returns a copy of the active game. The second return value is false, if
+// the game is not cached (e.g. already finished or the cache is not loaded).
+func (c *GameCache) Get(id int) (*Game, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	game, ok := c.games[id]
+	if !ok {
+		return nil, false
+	}
+	return copyGame(game), true
+}
+
+// GetAll returns copies of all active games ordered by id.
+func (c *GameCache) GetAll() []Game {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	games := make([]Game, 0, len(c.games))
+	for _, game := range c.games {
+		games = append(games, *copyGame(game))
+	}
+	sortGamesByID(games)
+	return games
+}
+
+// GetByPlayer returns copies of all active games of the player ordered by id.
+func (c *GameCache) GetByPlayer(playerID int) []Game {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	games := make([]Game, 0, len(c.byPlayer[playerID]))
+	for id := range c.byPlayer[playerID] {
+		games = append(games, *copyGame(c.games[id]))
+	}
+	sortGamesByID(games)
+	return games
+}
+
+func sortGamesByID(games []Game) {
+	sort.Slice(games, func(i, j int) bool { return games[i].ID < games[j].ID })
+}
+
+// CheckConsistency compares the cache against the active games stored in the
+// database and returns a human readable description of every difference.
+func (c *GameCache) CheckConsistency() ([]string, error) {
+	dbGames, err := DB_Get_Active_Games()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	problems := make([]string, 0)
+	if !c.loaded {
+		return append(problems, "cache is not loaded"), nil
+	}
+
+	seen := make(map[int]bool)
+	for _, dbGame := range dbGames {
+		seen[dbGame.ID] = true
+		cached, ok := c.games[dbGame.ID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("game %d is active in the database but not cached", dbGame.ID))
+			continue
+		}
+		if cached.Player1ID != dbGame.Player1ID || cached.Player2ID != dbGame.Player2ID {
+			problems = append(problems, fmt.Sprintf("game %d: players differ (cache: %d vs %d, db: %d vs %d)",
+				dbGame.ID, cached.Player1ID, cached.Player2ID, dbGame.Player1ID, dbGame.Player2ID))
+		}
+		if cached.DrawOffer != dbGame.DrawOffer {
+			problems = append(problems, fmt.Sprintf("game %d: draw offer differs (cache: %d, db: %d)",
+				dbGame.ID, cached.DrawOffer, dbGame.DrawOffer))
+		}
+		if len(cached.GameState.History) != len(dbGame.GameState.History) {
+			problems = append(problems, fmt.Sprintf("game %d: history length differs (cache: %d, db: %d)",
+				dbGame.ID, len(cached.GameState.History), len(dbGame.GameState.History)))
+			continue
+		}
+		for i, turn := range dbGame.GameState.History {
+			if !turn.Eq(cached.GameState.History[i]) {
+				problems = append(problems, fmt.Sprintf("game %d: turn %d differs (cache: %v, db: %v)",
+					dbGame.ID, i+1, cached.GameState.History[i], turn))
+				break
+			}
+		}
+	}
+
+	for id := range c.games {
+		if !seen[id] {
+			problems = append(problems, fmt.Sprintf("game %d is cached but not active in the database", id))
+		}
+	}
+
+	for playerID, ids := range c.byPlayer {
+		for id := range ids {
+			game, ok := c.games[id]
+			if !ok || (game.Player1ID != playerID && game.Player2ID != playerID) {
+				problems = append(problems, fmt.Sprintf("player index of player %d points to wrong game %d", playerID, id))
+			}
+		}
+	}
+
+	return problems, nil
+}
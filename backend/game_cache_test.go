@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestGameCacheUpdateIgnoresOutdatedGames(t *testing.T) {
+	cache := NewGameCache()
+	cache.loaded = true
+
+	state := NewGameState(5, 5)
+	game := &Game{ID: 1, Player1ID: 1, Player2ID: 2, GameState: &state}
+	cache.Update(game)
+
+	stale := copyGame(game)
+	stale.DrawOffer = 1
+	if !game.GameState.applyAction(game.GameState.PossibleMoves()[0]) {
+		t.Fatal("first move was rejected")
+	}
+	cache.Update(game)
+
+	cache.Update(stale)
+	cached, ok := cache.Get(1)
+	if !ok {
+		t.Fatal("game is not cached")
+	}
+	if len(cached.GameState.History) != 1 || cached.DrawOffer != 0 {
+		t.Errorf("outdated game replaced the cache: %d plies, draw offer %d", len(cached.GameState.History), cached.DrawOffer)
+	}
+
+	// updates without a move are kept
+	game.DrawOffer = 2
+	cache.Update(game)
+	if cached, _ := cache.Get(1); cached.DrawOffer != 2 {
+		t.Errorf("draw offer is %d, want 2", cached.DrawOffer)
+	}
+
+	game.Outcome = 1
+	cache.Update(game)
+	if _, ok := cache.Get(1); ok {
+		t.Error("finished game is still cached")
+	}
+}
@@ -23,30 +23,83 @@ func (t *Turn) Eq(other Turn) bool {
 }
 
 type GameState struct {
-	Rows    int     `json:"rows"`
-	Cols    int     `json:"cols"`
-	History []Turn  `json:"history"`
-	Board   [][]int `json:"board"`
+	Rows    int         `json:"rows"`
+	Cols    int         `json:"cols"`
+	History []Turn      `json:"history"`
+	Board   [][]int     `json:"board"`
+	Rules   RuleVariant `json:"rules"`
+
+	hash     uint64 // zobrist hash, updated incrementally by applyAction
+	startPly int    // plies played before the history started (positions set up with player 2 to move)
+	start    string // position the game started from, "" for the standard setup
+}
+
+// NewGameState creates the starting position: player 1 fills the first row,
+// player 2 the last one.
+func NewGameState(rows int, cols int) GameState {
+	// create a board
+	board := make([][]int, rows)
+	for i := range board {
+		board[i] = make([]int, cols)
+	}
+
+	// setup players
+	for i := 0; i < cols; i++ {
+		board[0][i] = 1      // Player 1
+		board[rows-1][i] = 2 // Player 2
+	}
+
+	return GameState{
+		Rows:    rows,
+		Cols:    cols,
+		History: make([]Turn, 0),
+		Board:   board,
+		hash:    zobristHash(board, 1),
+	}
+}
+
+// gameStateAlias has the fields of GameState without its MarshalJSON, to
+// avoid recursion
+type gameStateAlias GameState
+
+// gameStateJSON is a GameState as it is sent to clients, with the fields
+// derived from the board.
+type gameStateJSON struct {
+	gameStateAlias
+	GameOver      bool   `json:"gameOver"`
+	Winner        int    `json:"winner"`
+	MoveOptions   []Turn `json:"moveOptions"`
+	CurrentPlayer int    `json:"currentPlayer"`
+	Hash          string `json:"hash"`
+	Position      string `json:"position"`
+	Start         string `json:"start,omitempty"`
+}
+
+// movesAndWinner returns the legal moves and the winner, the moves are
+// needed for the winner as well, such that they are only generated once.
+func (g *GameState) movesAndWinner() ([]Turn, int) {
+	moves := g.PossibleMoves()
+	winner := g.winnerOnBoard()
+	if winner == 0 && len(moves) == 0 {
+		winner = g.noMovesResult()
+	}
+	return moves, winner
 }
 
 // Implement the json.Marshaler interface
 func (g GameState) MarshalJSON() ([]byte, error) {
-	// Create an alias to avoid recursion
-	type Alias GameState
+	moves, winner := g.movesAndWinner()
 
 	// Embed the original struct with derived fields
-	return json.Marshal(&struct {
-		Alias
-		GameOver      bool   `json:"gameOver"`
-		Winner        int    `json:"winner"`
-		MoveOptions   []Turn `json:"moveOptions"`
-		CurrentPlayer int    `json:"currentPlayer"`
-	}{
-		Alias:         Alias(g),
-		GameOver:      g.IsEnd(),
-		Winner:        g.GetWinner(),
-		MoveOptions:   g.PossibleMoves(),
-		CurrentPlayer: g.NextPlayer(),
+	return json.Marshal(&gameStateJSON{
+		gameStateAlias: gameStateAlias(g),
+		GameOver:       winner != 0,
+		Winner:         winner,
+		MoveOptions:    moves,
+		CurrentPlayer:  g.NextPlayer(),
+		Hash:           fmt.Sprintf("%016x", g.hash),
+		Position:       g.Position(),
+		Start:          g.start,
 	})
 }
 
@@ -62,15 +115,26 @@ func (g *GameState) Display() {
 	}
 }
 
+// StartPosition returns the custom position the game started from, "" if it
+// started from the standard setup.
+func (g *GameState) StartPosition() string {
+	return g.start
+}
+
+// Hash returns the zobrist hash of the position (board and side to move).
+func (g *GameState) Hash() uint64 {
+	return g.hash
+}
+
 func (g *GameState) NextPlayer() int {
-	return len(g.History)%2 + 1
+	return (g.startPly+len(g.History))%2 + 1
 }
 
 func (g *GameState) IsEnd() bool {
-	return g.GetWinner() != 0 || len(g.PossibleMoves()) == 0
+	return g.GetWinner() != 0
 }
 
-func (g *GameState) GetWinner() int {
+func (g *GameState) reachedLastRow() int {
 	for i := 0; i < g.Cols; i++ {
 		if g.Board[0][i] == 2 {
 			return 2
@@ -78,73 +142,268 @@ func (g *GameState) GetWinner() int {
 			return 1
 		}
 	}
+	return 0
+}
+
+func (g *GameState) countPawns(player int) int {
+	count := 0
+	for _, row := range g.Board {
+		for _, cell := range row {
+			if cell == player {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// winnerOnBoard returns the winner that is decided by the pawns on the board
+// alone (0 if none).
+func (g *GameState) winnerOnBoard() int {
+	if winner := g.reachedLastRow(); winner != 0 {
+		return winner
+	}
+	if g.Rules.WinOnNoPawns {
+		if g.countPawns(1) == 0 {
+			return 2
+		}
+		if g.countPawns(2) == 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// noMovesResult is the outcome if the player to move has no legal move.
+func (g *GameState) noMovesResult() int {
+	if g.Rules.StalemateLoses {
+		return 3 - g.NextPlayer()
+	}
 	// draw
-	if len(g.PossibleMoves()) == 0 {
-		return -1
+	return -1
+}
+
+func (g *GameState) GetWinner() int {
+	if winner := g.winnerOnBoard(); winner != 0 {
+		return winner
+	}
+	if !g.HasMoves() {
+		return g.noMovesResult()
 	}
 	return 0
 }
 
-func (g *GameState) PossibleMoves() []Turn {
+// enPassantSquare returns the cell a pawn skipped with a double step in the
+// last move, if it can be captured en passant.
+func (g *GameState) enPassantSquare() (int, int, bool) {
+	if !g.Rules.EnPassant || len(g.History) == 0 {
+		return 0, 0, false
+	}
+	last := g.History[len(g.History)-1]
+	if last.SourceCol != last.DestCol || (last.DestRow-last.SourceRow != 2 && last.SourceRow-last.DestRow != 2) {
+		return 0, 0, false
+	}
+	return (last.SourceRow + last.DestRow) / 2, last.DestCol, true
+}
+
+// MoveIterator enumerates the legal moves of a GameState without allocating.
+// The order is the same as the one of PossibleMoves. The game state must not
+// be modified while iterating.
+type MoveIterator struct {
+	g      *GameState
+	player int
+	dy     int
+	home   int // row on which double steps are allowed
+	turnID int
+
+	epRow int // en passant square, -1 if none
+	epCol int
+
+	col  int
+	row  int
+	step int // next step to check: 0-2 for dx = -1, 0, 1 and 3 for a double step
+}
+
+func (g *GameState) Moves() MoveIterator {
 	np := g.NextPlayer()
-	nextMoves := make([]Turn, 0)
-	turnID := len(g.History) + 1
-
-	for col := 0; col < g.Cols; col++ {
-		for row := 0; row < g.Rows; row++ {
-			if g.Board[row][col] == np {
-				var dy int
-				if np == 1 {
-					dy = 1
-				} else {
-					dy = -1
-				}
+	dy, home := 1, 0
+	if np == 2 {
+		dy, home = -1, g.Rows-1
+	}
+	if !g.Rules.DoubleStep {
+		home = -1
+	}
 
-				for _, dx := range []int{-1, 0, 1} {
-					x := col + dx
-					y := row + dy
+	epRow, epCol, ok := g.enPassantSquare()
+	if !ok {
+		epRow, epCol = -1, -1
+	}
 
-					if x < 0 || x >= g.Cols || y < 0 || y >= g.Rows {
+	return MoveIterator{
+		g:      g,
+		player: np,
+		dy:     dy,
+		home:   home,
+		turnID: len(g.History) + 1,
+		epRow:  epRow,
+		epCol:  epCol,
+	}
+}
+
+// Next returns the next legal move. The second return value is false, once
+// all moves have been returned.
+func (it *MoveIterator) Next() (Turn, bool) {
+	g := it.g
+	for it.col < g.Cols {
+		if g.Board[it.row][it.col] == it.player {
+			for it.step <= 3 {
+				step := it.step
+				it.step++
+
+				dx, dy := step-1, it.dy
+				if step == 3 {
+					if it.row != it.home {
 						continue
 					}
+					dx, dy = 0, 2*it.dy
+				}
 
-					if dx != 0 && g.Board[y][x] != np && g.Board[y][x] != 0 {
-						nextMoves = append(nextMoves, Turn{
-							TurnID:    turnID,
-							DestRow:   y,
-							DestCol:   x,
-							SourceRow: row,
-							SourceCol: col,
-							Player:    np,
-						})
-					} else if dx == 0 && g.Board[y][x] == 0 {
-						nextMoves = append(nextMoves, Turn{
-							TurnID:    turnID,
-							DestRow:   y,
-							DestCol:   x,
-							SourceRow: row,
-							SourceCol: col,
-							Player:    np,
-						})
-					}
+				x := it.col + dx
+				y := it.row + dy
+				if x < 0 || x >= g.Cols || y < 0 || y >= g.Rows {
+					continue
+				}
+
+				var legal bool
+				switch {
+				case dx != 0:
+					legal = (g.Board[y][x] != it.player && g.Board[y][x] != 0) || (y == it.epRow && x == it.epCol)
+				case step == 3:
+					legal = g.Board[it.row+it.dy][x] == 0 && g.Board[y][x] == 0
+				default:
+					legal = g.Board[y][x] == 0
+				}
+
+				if legal {
+					return Turn{
+						TurnID:    it.turnID,
+						DestRow:   y,
+						DestCol:   x,
+						SourceRow: it.row,
+						SourceCol: it.col,
+						Player:    it.player,
+					}, true
 				}
 			}
 		}
+
+		// advance to the next cell (column major)
+		it.step = 0
+		it.row++
+		if it.row == g.Rows {
+			it.row = 0
+			it.col++
+		}
+	}
+	return Turn{}, false
+}
+
+func (g *GameState) HasMoves() bool {
+	it := g.Moves()
+	_, ok := it.Next()
+	return ok
+}
+
+func (g *GameState) PossibleMoves() []Turn {
+	nextMoves := make([]Turn, 0)
+	it := g.Moves()
+	for move, ok := it.Next(); ok; move, ok = it.Next() {
+		nextMoves = append(nextMoves, move)
 	}
 	return nextMoves
 }
 
-func (g *GameState) applyAction(action Turn) bool {
-	moves := g.PossibleMoves()
+// MoveDelta holds everything needed to revert a move done by MakeMove.
+type MoveDelta struct {
+	Turn        Turn
+	Captured    int // player number of the captured pawn, 0 if none
+	CapturedRow int // differs from the destination for en passant captures
+	CapturedCol int
+	hash        uint64
+}
 
-	for _, move := range moves {
-		if move.Eq(action) {
-			drow, dcol := action.DestRow, action.DestCol
-			srow, scol := action.SourceRow, action.SourceCol
-			g.Board[drow][dcol] = action.Player
-			g.Board[srow][scol] = 0
+// MakeMove performs the move without checking whether it is legal, which is
+// the job of the caller (e.g. by taking it from Moves). The returned delta
+// can be passed to UnmakeMove to restore the previous state.
+func (g *GameState) MakeMove(action Turn) MoveDelta {
+	drow, dcol := action.DestRow, action.DestCol
+	srow, scol := action.SourceRow, action.SourceCol
+
+	delta := MoveDelta{
+		Turn:        action,
+		Captured:    g.Board[drow][dcol],
+		CapturedRow: drow,
+		CapturedCol: dcol,
+		hash:        g.hash,
+	}
+
+	// a diagonal move to an empty cell is an en passant capture
+	if delta.Captured == 0 && scol != dcol {
+		delta.CapturedRow = srow
+		delta.Captured = g.Board[srow][dcol]
+	}
+
+	if delta.Captured != 0 {
+		g.hash ^= zobristKey(delta.CapturedRow, delta.CapturedCol, delta.Captured)
+		g.Board[delta.CapturedRow][delta.CapturedCol] = 0
+	}
+	g.hash ^= zobristKey(srow, scol, action.Player) ^ zobristKey(drow, dcol, action.Player) ^ zobristSideKey
+
+	// the en passant square is part of the position
+	if _, col, ok := g.enPassantSquare(); ok {
+		g.hash ^= zobristEnPassantKey(col)
+	}
+
+	g.Board[drow][dcol] = action.Player
+	g.Board[srow][scol] = 0
 
-			g.History = append(g.History, action)
+	g.History = append(g.History, action)
+
+	if _, col, ok := g.enPassantSquare(); ok {
+		g.hash ^= zobristEnPassantKey(col)
+	}
+	return delta
+}
+
+// UnmakeMove reverts the last move. Deltas have to be reverted in reverse
+// order of their creation.
+func (g *GameState) UnmakeMove(delta MoveDelta) {
+	action := delta.Turn
+	g.Board[action.SourceRow][action.SourceCol] = action.Player
+	g.Board[action.DestRow][action.DestCol] = 0
+	if delta.Captured != 0 {
+		g.Board[delta.CapturedRow][delta.CapturedCol] = delta.Captured
+	}
+	g.History = g.History[:len(g.History)-1]
+	g.hash = delta.hash
+}
+
+// Clone returns a deep copy of the game state.
+func (g *GameState) Clone() *GameState {
+	cpy := *g
+	cpy.History = append(make([]Turn, 0, len(g.History)), g.History...)
+	cpy.Board = make([][]int, len(g.Board))
+	for i, row := range g.Board {
+		cpy.Board[i] = append(make([]int, 0, len(row)), row...)
+	}
+	return &cpy
+}
+
+func (g *GameState) applyAction(action Turn) bool {
+	it := g.Moves()
+	for move, ok := it.Next(); ok; move, ok = it.Next() {
+		if move.Eq(action) {
+			g.MakeMove(action)
 			return true
 		}
 	}
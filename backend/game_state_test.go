@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// checkUnmake makes and unmakes every legal move and compares the state with
+// the one before.
+func checkUnmake(t *testing.T, g *GameState) {
+	t.Helper()
+	before := g.Clone()
+	it := before.Moves()
+	for move, ok := it.Next(); ok; move, ok = it.Next() {
+		delta := g.MakeMove(move)
+		if g.Hash() != scratchHash(g) {
+			t.Fatalf("%s: hash after %s differs from the hash from scratch", before.Position(), move.Notation())
+		}
+		g.UnmakeMove(delta)
+		if !reflect.DeepEqual(g.Board, before.Board) || !reflect.DeepEqual(g.History, before.History) || g.Hash() != before.Hash() {
+			t.Fatalf("%s: unmake of %s left %s", before.Position(), move.Notation(), g.Position())
+		}
+	}
+}
+
+func TestMakeUnmakeDoubleStepAndEnPassant(t *testing.T) {
+	state := NewGameState(6, 3)
+	state.Rules = RuleVariant{DoubleStep: true, EnPassant: true}
+
+	for _, move := range []string{"a1-a3", "c6-c5", "a3-a4", "b6-b4"} {
+		checkUnmake(t, &state)
+		playMoves(t, &state, move)
+	}
+	checkUnmake(t, &state)
+
+	// b4 skipped b5 with its double step, a4 captures it there
+	capture, err := state.ParseMove("a4xb5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := state.Clone()
+	delta := state.MakeMove(capture)
+	if delta.Captured != 2 || delta.CapturedRow != 3 || delta.CapturedCol != 1 {
+		t.Errorf("en passant delta %+v, want the pawn on b4", delta)
+	}
+	if state.Board[3][1] != 0 || state.Board[4][1] != 1 {
+		t.Errorf("en passant capture left %s", state.Position())
+	}
+	state.UnmakeMove(delta)
+	if state.Position() != before.Position() || state.Board[3][1] != 2 || state.Hash() != before.Hash() {
+		t.Errorf("unmake of the en passant capture left %s", state.Position())
+	}
+
+	// the capture is only possible right after the double step
+	playMoves(t, &state, "c1-c2", "c5-c4")
+	if _, err := state.ParseMove("a4xb5"); err == nil {
+		t.Error("en passant capture accepted one move too late")
+	}
+}
+
+func TestMakeUnmakeRandomGames(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	for _, rules := range ruleVariants {
+		for _, size := range [][2]int{{5, 3}, {6, 4}, {8, 8}} {
+			for game := 0; game < 10; game++ {
+				state := NewGameState(size[0], size[1])
+				state.Rules = rules
+				for !state.IsEnd() {
+					checkUnmake(t, &state)
+					moves := state.PossibleMoves()
+					state.MakeMove(moves[rng.Intn(len(moves))])
+				}
+			}
+		}
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	state := NewGameState(5, 3)
+	playMoves(t, &state, "a1-a2")
+	clone := state.Clone()
+	playMoves(t, clone, "b5-b4", "a2-a3")
+
+	if len(state.History) != 1 || state.Board[1][0] != 1 || state.Board[4][1] != 2 {
+		t.Errorf("moves on the clone changed the original: %s", state.Position())
+	}
+	if clone.Hash() == state.Hash() {
+		t.Error("clone and original have the same hash after different moves")
+	}
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/IdontKer/RLarena/arenapb"
+)
+
+// The gRPC API serves bots that play many games at once, see
+// arenapb/arena.proto. It uses the same game logic as the HTTP handlers,
+// errors carry the error code of the HTTP API as reason of an ErrorInfo.
+
+const GRPC_ERROR_DOMAIN = "rlarena"
+
+var (
+	grpcAddr       = ":8082"
+	grpcReflection = false
+)
+
+// loadGrpcConfig reads GRPC_ADDR, "off" disables the gRPC API, and
+// GRPC_REFLECTION.
+func loadGrpcConfig() {
+	if value := os.Getenv("GRPC_ADDR"); value != "" {
+		grpcAddr = value
+	}
+	if value := os.Getenv("GRPC_REFLECTION"); value != "" {
+		reflection, err := strconv.ParseBool(value)
+		if err != nil {
+			slog.Error("Invalid GRPC_REFLECTION, reflection is disabled", "value", value)
+			return
+		}
+		grpcReflection = reflection
+	}
+}
+
+var grpcCodes = map[ErrorCode]codes.Code{
+	ERR_BAD_REQUEST:   codes.InvalidArgument,
+	ERR_INVALID_JSON:  codes.InvalidArgument,
+	ERR_INVALID_ID:    codes.InvalidArgument,
+	ERR_UNAUTHORIZED:  codes.Unauthenticated,
+	ERR_INVALID_TOKEN: codes.Unauthenticated,
+	ERR_FORBIDDEN:     codes.PermissionDenied,
+	ERR_NOT_YOUR_GAME: codes.PermissionDenied,
+	ERR_NOT_FOUND:     codes.NotFound,
+	ERR_NOT_ALLOWED:   codes.Unimplemented,
+	ERR_CONFLICT:      codes.FailedPrecondition,
+	ERR_NAME_TAKEN:    codes.AlreadyExists,
+	ERR_BOT_RETIRED:   codes.FailedPrecondition,
+	ERR_NOT_YOUR_TURN: codes.FailedPrecondition,
+	ERR_GAME_OVER:     codes.FailedPrecondition,
+	ERR_GAME_CHANGED:  codes.Aborted,
+	ERR_NO_DRAW_OFFER: codes.FailedPrecondition,
+	ERR_ILLEGAL_MOVE:  codes.InvalidArgument,
+	ERR_RATE_LIMITED:  codes.ResourceExhausted,
+	ERR_INTERNAL:      codes.Internal,
+}
+
+// grpcStatus converts an error of the API into a gRPC status with the error
+// code as reason.
+func grpcStatus(apiErr *APIError, metadata map[string]string) *status.Status {
+	code, ok := grpcCodes[apiErr.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	st := status.New(code, apiErr.Message)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   string(apiErr.Code),
+		Domain:   GRPC_ERROR_DOMAIN,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// grpcError converts the errors of the game logic like apiError and logs
+// the unexpected ones.
+func grpcError(method string, err error) error {
+	apiErr := apiError(err)
+	if apiErr.Code == ERR_INTERNAL {
+		slog.Error("Error serving gRPC call", "method", method, "error", err)
+	}
+	return grpcStatus(apiErr, nil).Err()
+}
+
+// grpcPlayer returns the player of the token in the metadata of the call.
+func grpcPlayer(ctx context.Context) (*Player, error) {
+	token := ""
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		scheme, value, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+	}
+	return playerByToken(token)
+}
+
+// ------------------------------
+// Messages
+
+func toProtoPlayer(player *Player) *arenapb.Player {
+	return &arenapb.Player{
+		Id:         int64(player.ID),
+		Name:       player.Name,
+		CurrentElo: int32(player.CurrentElo),
+		Retired:    player.Retired,
+		OwnerId:    int64(player.OwnerID),
+		TeamId:     int64(player.TeamID),
+	}
+}
+
+func toProtoTurn(turn Turn) *arenapb.Turn {
+	return &arenapb.Turn{
+		TurnId:    int32(turn.TurnID),
+		SourceRow: int32(turn.SourceRow),
+		SourceCol: int32(turn.SourceCol),
+		DestRow:   int32(turn.DestRow),
+		DestCol:   int32(turn.DestCol),
+		Player:    int32(turn.Player),
+	}
+}
+
+func fromProtoTurn(turn *arenapb.Turn) Turn {
+	return Turn{
+		TurnID:    int(turn.GetTurnId()),
+		SourceRow: int(turn.GetSourceRow()),
+		SourceCol: int(turn.GetSourceCol()),
+		DestRow:   int(turn.GetDestRow()),
+		DestCol:   int(turn.GetDestCol()),
+		Player:    int(turn.GetPlayer()),
+	}
+}
+
+func toProtoTurns(turns []Turn) []*arenapb.Turn {
+	result := make([]*arenapb.Turn, len(turns))
+	for i, turn := range turns {
+		result[i] = toProtoTurn(turn)
+	}
+	return result
+}
+
+// toProtoGameState has the derived fields of the JSON form as well.
+func toProtoGameState(state *GameState) *arenapb.GameState {
+	moves, winner := state.movesAndWinner()
+	board := make([]*arenapb.Row, len(state.Board))
+	for i, row := range state.Board {
+		cells := make([]int32, len(row))
+		for j, cell := range row {
+			cells[j] = int32(cell)
+		}
+		board[i] = &arenapb.Row{Cells: cells}
+	}
+	rules := ""
+	if !state.Rules.IsStandard() {
+		rules = state.Rules.String()
+	}
+	return &arenapb.GameState{
+		Rows:          int32(state.Rows),
+		Cols:          int32(state.Cols),
+		Board:         board,
+		History:       toProtoTurns(state.History),
+		Rules:         rules,
+		CurrentPlayer: int32(state.NextPlayer()),
+		MoveOptions:   toProtoTurns(moves),
+		GameOver:      winner != 0,
+		Winner:        int32(winner),
+		Position:      state.Position(),
+	}
+}
+
+func toProtoGame(game *Game) *arenapb.Game {
+	return &arenapb.Game{
+		Id:          int64(game.ID),
+		Player1Id:   int64(game.Player1ID),
+		Player2Id:   int64(game.Player2ID),
+		Outcome:     int32(game.Outcome),
+		CreatedAt:   game.CreatedAt,
+		EndedAt:     game.EndedAt,
+		Termination: game.Termination,
+		DrawOffer:   int32(game.DrawOffer),
+		State:       toProtoGameState(game.GameState),
+		Unrated:     game.Unrated,
+	}
+}
+
+func toProtoGames(games []Game) []*arenapb.Game {
+	result := make([]*arenapb.Game, len(games))
+	for i := range games {
+		result[i] = toProtoGame(&games[i])
+	}
+	return result
+}
+
+// ------------------------------
+// Service
+
+type arenaServer struct {
+	arenapb.UnimplementedArenaServer
+}
+
+func (s *arenaServer) SignUp(_ context.Context, req *arenapb.SignUpRequest) (*arenapb.SignUpResponse, error) {
+	id, token, err := signUp(req.GetName(), 0)
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_SignUp_FullMethodName, err)
+	}
+	return &arenapb.SignUpResponse{Id: int64(id), Token: token}, nil
+}
+
+func (s *arenaServer) GetPlayer(ctx context.Context, _ *arenapb.GetPlayerRequest) (*arenapb.Player, error) {
+	player, err := grpcPlayer(ctx)
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_GetPlayer_FullMethodName, err)
+	}
+	return toProtoPlayer(player), nil
+}
+
+func (s *arenaServer) GetGame(_ context.Context, req *arenapb.GetGameRequest) (*arenapb.Game, error) {
+	game, err := getGame(int(req.GetId()))
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_GetGame_FullMethodName, err)
+	}
+	return toProtoGame(game), nil
+}
+
+func (s *arenaServer) ListActiveGames(ctx context.Context, _ *arenapb.ListActiveGamesRequest) (*arenapb.ActiveGames, error) {
+	player, err := grpcPlayer(ctx)
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_ListActiveGames_FullMethodName, err)
+	}
+	active, err := activeGamesOf(player)
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_ListActiveGames_FullMethodName, err)
+	}
+	return &arenapb.ActiveGames{
+		MyTurn:   toProtoGames(active.MyTurn),
+		Awaiting: toProtoGames(active.Awaiting),
+	}, nil
+}
+
+func (s *arenaServer) SubmitTurn(ctx context.Context, req *arenapb.SubmitTurnRequest) (*arenapb.Game, error) {
+	player, err := grpcPlayer(ctx)
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_SubmitTurn_FullMethodName, err)
+	}
+	err = applyAction(*player, int(req.GetGameId()), fromProtoTurn(req.GetTurn()))
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_SubmitTurn_FullMethodName, err)
+	}
+	game, err := getGame(int(req.GetGameId()))
+	if err != nil {
+		return nil, grpcError(arenapb.Arena_SubmitTurn_FullMethodName, err)
+	}
+	return toProtoGame(game), nil
+}
+
+// gameEvents collects the events of a player topic and keeps only the latest
+// one of every game. It drains the subscription at once, such that a stream
+// that is slow to send is not dropped by the hub for the number of its games.
+type gameEvents struct {
+	mutex   sync.Mutex
+	pending map[int]Event // game id -> latest event
+	order   []int         // game ids in the order of their first pending event
+	closed  bool          // the subscription was closed
+	ready   chan struct{} // signaled when events are pending
+}
+
+func collectGameEvents(ch chan Event) *gameEvents {
+	e := &gameEvents{pending: make(map[int]Event), ready: make(chan struct{}, 1)}
+	go func() {
+		for event := range ch {
+			e.add(event)
+		}
+		e.mutex.Lock()
+		e.closed = true
+		e.mutex.Unlock()
+		e.signal()
+	}()
+	return e
+}
+
+func (e *gameEvents) add(event Event) {
+	var gameID int
+	switch data := event.Data.(type) {
+	case TurnEvent:
+		gameID = data.GameID
+	case GameEndEvent:
+		gameID = data.GameID
+	default:
+		return
+	}
+
+	e.mutex.Lock()
+	if _, ok := e.pending[gameID]; !ok {
+		e.order = append(e.order, gameID)
+	}
+	// the end of a game replaces its turn, a newer turn the older one
+	e.pending[gameID] = event
+	e.mutex.Unlock()
+	e.signal()
+}
+
+func (e *gameEvents) signal() {
+	select {
+	case e.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending events and whether the subscription was closed.
+func (e *gameEvents) take() ([]Event, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	taken := make([]Event, 0, len(e.order))
+	for _, gameID := range e.order {
+		taken = append(taken, e.pending[gameID])
+	}
+	e.pending = make(map[int]Event)
+	e.order = e.order[:0]
+	return taken, e.closed
+}
+
+// Play asks the bot for a move whenever it is its turn in one of its games
+// and applies the moves it sends. The moves are answered in order, turn
+// requests and game ends may come in between. Every move counts against the
+// rate limit of the play group.
+func (s *arenaServer) Play(stream arenapb.Arena_PlayServer) error {
+	ctx := stream.Context()
+	player, err := grpcPlayer(ctx)
+	if err != nil {
+		return grpcError(arenapb.Arena_Play_FullMethodName, err)
+	}
+	token, ip := grpcClient(ctx)
+
+	// subscribe before reading the running games, such that no turn is missed
+	topic := playerTopic(player.ID)
+	ch := events.Subscribe(topic)
+	defer events.Unsubscribe(topic, ch)
+	queue := collectGameEvents(ch)
+
+	active, err := activeGamesOf(player)
+	if err != nil {
+		return grpcError(arenapb.Arena_Play_FullMethodName, err)
+	}
+
+	// ply of the last turn request per game, such that a turn is only
+	// requested once
+	requested := make(map[int]int)
+	requestTurn := func(game *Game) error {
+		if game.Outcome != 0 || game.PlayerNumber(player.ID) != game.GameState.NextPlayer() {
+			return nil
+		}
+		ply := len(game.GameState.History)
+		if last, ok := requested[game.ID]; ok && last == ply {
+			return nil
+		}
+		requested[game.ID] = ply
+		return stream.Send(&arenapb.PlayResponse{
+			Event: &arenapb.PlayResponse_TurnRequest{TurnRequest: toProtoGame(game)},
+		})
+	}
+
+	for i := range active.MyTurn {
+		err = requestTurn(&active.MyTurn[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	// the stream is only sent to by this goroutine, the moves are received
+	// by another one
+	moves := make(chan *arenapb.PlayRequest)
+	received := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				received <- err
+				return
+			}
+			select {
+			case moves <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+
+		case err := <-received:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+
+		case req := <-moves:
+			result := &arenapb.MoveResult{GameId: req.GetGameId(), Accepted: true}
+			err := grpcAllowMove(token, ip)
+			if err == nil {
+				err = applyAction(*player, int(req.GetGameId()), fromProtoTurn(req.GetTurn()))
+			}
+			if err != nil {
+				apiErr := apiError(err)
+				if apiErr.Code == ERR_INTERNAL {
+					slog.Error("Error applying action", "id", req.GetGameId(), "error", err)
+				}
+				result.Accepted = false
+				result.Code = string(apiErr.Code)
+				result.Message = apiErr.Message
+			}
+			err = stream.Send(&arenapb.PlayResponse{
+				Event: &arenapb.PlayResponse_MoveResult{MoveResult: result},
+			})
+			if err != nil {
+				return err
+			}
+
+		case <-queue.ready:
+			pending, closed := queue.take()
+			for _, event := range pending {
+				switch data := event.Data.(type) {
+				case TurnEvent:
+					game, err := getGame(data.GameID)
+					if err != nil {
+						slog.Error("Error getting game for turn request", "id", data.GameID, "error", err)
+						continue
+					}
+					err = requestTurn(game)
+					if err != nil {
+						return err
+					}
+				case GameEndEvent:
+					delete(requested, data.GameID)
+					err := stream.Send(&arenapb.PlayResponse{
+						Event: &arenapb.PlayResponse_GameEnd{GameEnd: &arenapb.GameEnd{
+							GameId:      int64(data.GameID),
+							Outcome:     int32(data.Outcome),
+							Termination: data.Termination,
+						}},
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if closed {
+				return status.Error(codes.ResourceExhausted, "Stream fell behind, reconnect")
+			}
+		}
+	}
+}
+
+// ------------------------------
+// Interceptors
+
+var grpcRateLimitGroups = map[string]string{
+	arenapb.Arena_SignUp_FullMethodName:          RATE_LIMIT_AUTH,
+	arenapb.Arena_GetPlayer_FullMethodName:       RATE_LIMIT_READ,
+	arenapb.Arena_GetGame_FullMethodName:         RATE_LIMIT_PLAY,
+	arenapb.Arena_ListActiveGames_FullMethodName: RATE_LIMIT_PLAY,
+	arenapb.Arena_SubmitTurn_FullMethodName:      RATE_LIMIT_PLAY,
+	arenapb.Arena_Play_FullMethodName:            RATE_LIMIT_PLAY,
+}
+
+// grpcClient returns the token and the address of the client of the call.
+func grpcClient(ctx context.Context) (string, string) {
+	token := ""
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		_, token, _ = strings.Cut(values[0], " ")
+	}
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	return strings.TrimSpace(token), ip
+}
+
+// grpcAllow applies the rate limits of the HTTP API to a call, opening a
+// stream counts as one request.
+func grpcAllow(ctx context.Context, method string) error {
+	group, ok := grpcRateLimitGroups[method]
+	if !ok {
+		group = RATE_LIMIT_READ
+	}
+
+	token, ip := grpcClient(ctx)
+	allowed, wait := rateLimiter.Allow(group, token, ip)
+	if allowed {
+		return nil
+	}
+	retryAfter := int(wait.Seconds()) + 1
+	st := grpcStatus(
+		NewAPIError(ERR_RATE_LIMITED, "Rate limit exceeded, retry after "+strconv.Itoa(retryAfter)+"s"),
+		map[string]string{"group": group, "retry_after": strconv.Itoa(retryAfter)},
+	)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Duration(retryAfter) * time.Second)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcAllowMove applies the rate limit of the play group to a move sent on
+// the Play stream.
+func grpcAllowMove(token string, ip string) error {
+	allowed, wait := rateLimiter.Allow(RATE_LIMIT_PLAY, token, ip)
+	if allowed {
+		return nil
+	}
+	retryAfter := int(wait.Seconds()) + 1
+	return NewAPIError(ERR_RATE_LIMITED, "Rate limit exceeded, retry after "+strconv.Itoa(retryAfter)+"s")
+}
+
+// grpcRecover converts a panic of a call into an internal error and logs
+// the stack, like Recover for HTTP.
+func grpcRecover(method string, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	slog.Error("Panic while serving gRPC call",
+		"error", recovered,
+		"method", method,
+		"stack", string(debug.Stack()),
+	)
+	*err = status.Error(codes.Internal, "Internal server error")
+}
+
+func grpcLog(ctx context.Context, method string, start time.Time, err error) {
+	remote := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	slog.Info("gRPC call",
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+		"remote", remote,
+	)
+}
+
+func grpcUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response any, err error) {
+	start := time.Now()
+	defer func() { grpcLog(ctx, info.FullMethod, start, err) }()
+	defer grpcRecover(info.FullMethod, &err)
+
+	err = grpcAllow(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcStreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	defer func() { grpcLog(stream.Context(), info.FullMethod, start, err) }()
+	defer grpcRecover(info.FullMethod, &err)
+
+	err = grpcAllow(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// serveGRPC serves the gRPC API on grpcAddr until the listener fails.
+func serveGRPC() {
+	if grpcAddr == "off" {
+		return
+	}
+	listener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		slog.Error("Error starting the gRPC API", "addr", grpcAddr, "error", err)
+		return
+	}
+
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(grpcUnaryInterceptor),
+		grpc.ChainStreamInterceptor(grpcStreamInterceptor),
+	)
+	arenapb.RegisterArenaServer(server, &arenaServer{})
+	if grpcReflection {
+		reflection.Register(server)
+	}
+
+	slog.Info("gRPC API is starting", "addr", grpcAddr, "reflection", grpcReflection)
+	err = server.Serve(listener)
+	if err != nil {
+		slog.Error("gRPC API stopped", "error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGameEventsKeepLatestPerGame(t *testing.T) {
+	ch := make(chan Event)
+	queue := collectGameEvents(ch)
+
+	// more events than the buffer of a subscription, nobody takes them
+	for ply := 0; ply < 2*EVENT_BUFFER_SIZE; ply++ {
+		ch <- Event{Type: EVENT_TURN, Data: TurnEvent{GameID: 1 + ply%3, Ply: ply}}
+	}
+	ch <- Event{Type: EVENT_END, Data: GameEndEvent{GameID: 2, Outcome: 1}}
+	ch <- Event{Type: EVENT_RATING, Data: RatingChange{GameID: 2}}
+	close(ch)
+
+	select {
+	case <-queue.ready:
+	case <-time.After(time.Second):
+		t.Fatal("no events are ready")
+	}
+	var pending []Event
+	closed := false
+	for deadline := time.Now().Add(time.Second); !closed && time.Now().Before(deadline); {
+		var taken []Event
+		taken, closed = queue.take()
+		pending = append(pending, taken...)
+	}
+	if !closed {
+		t.Fatal("closed subscription is not reported")
+	}
+
+	if len(pending) != 3 {
+		t.Fatalf("%d pending events, want one per game: %v", len(pending), pending)
+	}
+	for i, want := range []int{1, 2, 3} {
+		switch data := pending[i].Data.(type) {
+		case TurnEvent:
+			if data.GameID != want || data.Ply < 2*EVENT_BUFFER_SIZE-3 {
+				t.Errorf("event %d: %+v is not the latest turn of game %d", i, data, want)
+			}
+		case GameEndEvent:
+			if data.GameID != want || want != 2 {
+				t.Errorf("event %d: unexpected end %+v", i, data)
+			}
+		}
+	}
+	if _, ok := pending[1].Data.(GameEndEvent); !ok {
+		t.Errorf("end of game 2 did not replace its turn: %+v", pending[1])
+	}
+}
+
+func TestGrpcConfigKeepsReflectionOnInvalidValue(t *testing.T) {
+	discardLogs(t)
+	reflection := grpcReflection
+	t.Cleanup(func() { grpcReflection = reflection })
+
+	t.Setenv("GRPC_REFLECTION", "true")
+	loadGrpcConfig()
+	t.Setenv("GRPC_REFLECTION", "sometimes")
+	loadGrpcConfig()
+	if !grpcReflection {
+		t.Error("invalid GRPC_REFLECTION overwrote the setting")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"math/rand"
 	"os"
+	"sort"
 	"time"
 )
 
@@ -18,6 +19,27 @@ type DB_Game struct {
 	Outcome   int
 	Rows      int
 	Cols      int
+	CreatedAt int64  // unix seconds
+	EndedAt   int64  // unix seconds, 0 while the game is running
+	Rules     string // see RuleVariant.String
+
+	StartPosition string // "" for the standard setup
+	Termination   string // see termination.go, "" while the game is running
+	DrawOffer     int    // player with a pending draw offer, 0 for none
+	LastMoveAt    int64  // unix seconds, 0 before the first move
+}
+
+// columns of the Game table in the order expected by scanGame
+const DB_GAME_COLUMNS = "ID, Player1ID, Player2ID, Outcome, Rows, Cols, CreatedAt, EndedAt, Rules, StartPosition, Termination, DrawOffer, LastMoveAt"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGame(row rowScanner) (DB_Game, error) {
+	db_game := DB_Game{}
+	err := row.Scan(&db_game.ID, &db_game.Player1ID, &db_game.Player2ID, &db_game.Outcome, &db_game.Rows, &db_game.Cols, &db_game.CreatedAt, &db_game.EndedAt, &db_game.Rules, &db_game.StartPosition, &db_game.Termination, &db_game.DrawOffer, &db_game.LastMoveAt)
+	return db_game, err
 }
 
 type DB_Turn struct {
@@ -32,10 +54,33 @@ type DB_Turn struct {
 }
 
 type DB_Player struct {
-	ID          int
-	Name        string
-	SecretToken string
-	CurrentElo  int
+	ID         int
+	Name       string
+	CurrentElo int
+	OwnerID    int
+	RetiredAt  int64
+	TeamID     int
+}
+
+// columns of the Player table in the order expected by scanPlayer
+const DB_PLAYER_COLUMNS = "ID, Name, Elo, OwnerID, RetiredAt, TeamID"
+
+func scanPlayer(row rowScanner) (DB_Player, error) {
+	db_player := DB_Player{}
+	err := row.Scan(&db_player.ID, &db_player.Name, &db_player.CurrentElo, &db_player.OwnerID, &db_player.RetiredAt, &db_player.TeamID)
+	return db_player, err
+}
+
+func (db_player DB_Player) toPlayer(history []HistoryEntry) Player {
+	return Player{
+		ID:          db_player.ID,
+		Name:        db_player.Name,
+		CurrentElo:  db_player.CurrentElo,
+		OwnerID:     db_player.OwnerID,
+		Retired:     db_player.RetiredAt != 0,
+		TeamID:      db_player.TeamID,
+		GameHistory: history,
+	}
 }
 
 var db *sql.DB
@@ -55,7 +100,9 @@ func Db_open() (*sql.DB, error) {
 // Game Functions
 // ------------------------------
 
-func DB_Create_Game(player1_id int, player2_id int, rows int, cols int) (int, error) {
+// DB_Create_Game stores a new game starting from the given state (which
+// must not have a history yet).
+func DB_Create_Game(player1_id int, player2_id int, state *GameState) (int, error) {
 	slog.Debug("Create Game", "player1_id", player1_id, "player2_id", player2_id)
 	db, err := Db_open()
 	if err != nil {
@@ -64,12 +111,17 @@ func DB_Create_Game(player1_id int, player2_id int, rows int, cols int) (int, er
 	}
 	defer db.Close()
 
-	result, err := db.Exec("INSERT INTO Game (Player1ID, Player2ID, Outcome, Rows, Cols) VALUES (?, ?, ?, ?, ?)",
+	result, err := db.Exec("INSERT INTO Game (Player1ID, Player2ID, Outcome, Rows, Cols, CreatedAt, Rules, StartPosition, Player1VersionID, Player2VersionID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, "+DB_CURRENT_VERSION+", "+DB_CURRENT_VERSION+")",
 		player1_id,
 		player2_id,
 		0,
-		rows,
-		cols)
+		state.Rows,
+		state.Cols,
+		time.Now().Unix(),
+		state.Rules.String(),
+		state.StartPosition(),
+		player1_id,
+		player2_id)
 	if err != nil {
 		slog.Error("Error inserting new game to db", "error", err)
 		return -1, err
@@ -88,6 +140,12 @@ func reconstruct_game(db *sql.DB, db_game DB_Game) (*Game, error) {
 	rows := db_game.Rows
 	cols := db_game.Cols
 
+	rules, err := ParseRuleVariant(db_game.Rules)
+	if err != nil {
+		slog.Error("Error parsing rules", "gameID", db_game.ID, "rules", db_game.Rules, "error", err)
+		return nil, err
+	}
+
 	// load turns
 	turnResults, err := db.Query("SELECT * FROM Turn WHERE GameID = ?", db_game.ID)
 	if err != nil {
@@ -114,23 +172,10 @@ func reconstruct_game(db *sql.DB, db_game DB_Game) (*Game, error) {
 		history = append(history, turn)
 	}
 
-	// create a board
-	board := make([][]int, rows)
-	for i := range board {
-		board[i] = make([]int, cols)
-	}
-
-	// setup players
-	for i := 0; i < cols; i++ {
-		board[0][i] = 1      // Player 1
-		board[rows-1][i] = 2 // Player 2
-	}
-
-	state := GameState{
-		Rows:    rows,
-		Cols:    cols,
-		History: make([]Turn, 0),
-		Board:   board,
+	state, err := initialGameState(rows, cols, rules, db_game.StartPosition)
+	if err != nil {
+		slog.Error("Error creating initial state", "gameID", db_game.ID, "start", db_game.StartPosition, "error", err)
+		return nil, err
 	}
 
 	// apply all turns
@@ -146,11 +191,17 @@ func reconstruct_game(db *sql.DB, db_game DB_Game) (*Game, error) {
 	}
 
 	game := Game{
-		ID:        db_game.ID,
-		Player1ID: db_game.Player1ID,
-		Player2ID: db_game.Player2ID,
-		Outcome:   db_game.Outcome,
-		GameState: &state,
+		ID:          db_game.ID,
+		Player1ID:   db_game.Player1ID,
+		Player2ID:   db_game.Player2ID,
+		Outcome:     db_game.Outcome,
+		CreatedAt:   db_game.CreatedAt,
+		EndedAt:     db_game.EndedAt,
+		Termination: db_game.Termination,
+		DrawOffer:   db_game.DrawOffer,
+		LastMoveAt:  db_game.LastMoveAt,
+		Unrated:     db_game.StartPosition != "",
+		GameState:   state,
 	}
 
 	return &game, nil
@@ -165,9 +216,8 @@ func DB_Get_Game(id int) (*Game, error) {
 	defer db.Close()
 
 	// load game data
-	row := db.QueryRow("SELECT * FROM Game WHERE ID = ?", id)
-	db_game := DB_Game{}
-	err = row.Scan(&db_game.ID, &db_game.Player1ID, &db_game.Player2ID, &db_game.Outcome, &db_game.Rows, &db_game.Cols)
+	row := db.QueryRow("SELECT "+DB_GAME_COLUMNS+" FROM Game WHERE ID = ?", id)
+	db_game, err := scanGame(row)
 	if err != nil {
 		slog.Error("Error querying game by id", "id", id, "error", err)
 		return nil, err
@@ -190,7 +240,7 @@ func DB_Get_Games(startIdx int, endIdx int) ([]Game, error) {
 	defer db.Close()
 
 	// load game data
-	rows, err := db.Query("SELECT * FROM Game WHERE ID >= ? AND ID < ?", startIdx, endIdx)
+	rows, err := db.Query("SELECT "+DB_GAME_COLUMNS+" FROM Game WHERE ID >= ? AND ID < ? ORDER BY ID", startIdx, endIdx)
 	if err != nil {
 		slog.Error("Error querying game by id", "startIdx", startIdx, "endIdx", endIdx, "error", err)
 		return nil, err
@@ -198,8 +248,7 @@ func DB_Get_Games(startIdx int, endIdx int) ([]Game, error) {
 
 	games := make([]Game, 0)
 	for rows.Next() {
-		db_game := DB_Game{}
-		err = rows.Scan(&db_game.ID, &db_game.Player1ID, &db_game.Player2ID, &db_game.Outcome, &db_game.Rows, &db_game.Cols)
+		db_game, err := scanGame(rows)
 		if err != nil {
 			slog.Error("Error during reading games (get games query)", "error", err)
 			return nil, err
@@ -241,17 +290,83 @@ func DB_apply_action(action Turn, game *Game) error {
 		return err
 	}
 
-	// update game outcome
-	_, err = transaction.Exec("UPDATE Game SET Outcome = ? WHERE ID = ?", game.Outcome, game.ID)
+	// update game outcome, unless the game was ended concurrently (e.g. by a resignation)
+	result, err := transaction.Exec("UPDATE Game SET Outcome = ?, EndedAt = ?, Termination = ?, DrawOffer = ?, LastMoveAt = ? WHERE ID = ? AND Outcome = 0",
+		game.Outcome, game.EndedAt, game.Termination, game.DrawOffer, game.LastMoveAt, game.ID)
 	if err != nil {
 		transaction.Rollback()
 		return err
 	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		transaction.Rollback()
+		return ErrGameOver
+	}
 
 	transaction.Commit()
 	return nil
 }
 
+// DB_End_Game stores the outcome of a game ended without a move (resignation,
+// agreement, adjudication). It fails with ErrGameOver if the game already
+// ended and with ErrGameChanged if moves were added since the game was read.
+func DB_End_Game(game *Game) error {
+	db, err := Db_open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.Exec("UPDATE Game SET Outcome = ?, EndedAt = ?, Termination = ?, DrawOffer = 0 WHERE ID = ? AND Outcome = 0 AND (SELECT COUNT(*) FROM Turn WHERE GameID = ?) = ?",
+		game.Outcome, game.EndedAt, game.Termination, game.ID, game.ID, len(game.GameState.History))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		var outcome int
+		err = db.QueryRow("SELECT Outcome FROM Game WHERE ID = ?", game.ID).Scan(&outcome)
+		if err == nil && outcome == 0 {
+			return ErrGameChanged
+		}
+		return ErrGameOver
+	}
+	return nil
+}
+
+// DB_Set_Draw_Offer stores the pending draw offer of a running game. Like
+// DB_End_Game it fails with ErrGameChanged if moves were added since the game
+// was read, so that an outdated copy never replaces the cached game.
+func DB_Set_Draw_Offer(game *Game) error {
+	db, err := Db_open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.Exec("UPDATE Game SET DrawOffer = ? WHERE ID = ? AND Outcome = 0 AND (SELECT COUNT(*) FROM Turn WHERE GameID = ?) = ?",
+		game.DrawOffer, game.ID, game.ID, len(game.GameState.History))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		var outcome int
+		err = db.QueryRow("SELECT Outcome FROM Game WHERE ID = ?", game.ID).Scan(&outcome)
+		if err == nil && outcome == 0 {
+			return ErrGameChanged
+		}
+		return ErrGameOver
+	}
+	return nil
+}
+
 func DB_Get_Active_Games() ([]Game, error) {
 	db, err := Db_open()
 	if err != nil {
@@ -260,7 +375,7 @@ func DB_Get_Active_Games() ([]Game, error) {
 	}
 	defer db.Close()
 
-	rows, err := db.Query("SELECT * FROM Game WHERE Outcome = 0")
+	rows, err := db.Query("SELECT " + DB_GAME_COLUMNS + " FROM Game WHERE Outcome = 0")
 	if err != nil {
 		slog.Error("Error querying actives games", "error", err)
 		return nil, err
@@ -268,8 +383,7 @@ func DB_Get_Active_Games() ([]Game, error) {
 
 	db_games := make([]DB_Game, 0)
 	for rows.Next() {
-		db_game := DB_Game{}
-		err = rows.Scan(&db_game.ID, &db_game.Player1ID, &db_game.Player2ID, &db_game.Outcome, &db_game.Rows, &db_game.Cols)
+		db_game, err := scanGame(rows)
 		if err != nil {
 			slog.Error("Error during reading games (get active game query)", "error", err)
 			return nil, err
@@ -297,15 +411,14 @@ func DB_Get_Active_Games_By_Player(player *Player) ([]Game, error) {
 	}
 	defer db.Close()
 
-	rows, err := db.Query("SELECT * FROM Game WHERE Outcome = 0 AND (Player1ID = ? OR Player2ID = ?)", player.ID, player.ID)
+	rows, err := db.Query("SELECT "+DB_GAME_COLUMNS+" FROM Game WHERE Outcome = 0 AND (Player1ID = ? OR Player2ID = ?)", player.ID, player.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	db_games := make([]DB_Game, 0)
 	for rows.Next() {
-		db_game := DB_Game{}
-		err = rows.Scan(&db_game.ID, &db_game.Player1ID, &db_game.Player2ID, &db_game.Outcome, &db_game.Rows, &db_game.Cols)
+		db_game, err := scanGame(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -329,34 +442,56 @@ func DB_Get_Active_Games_By_Player(player *Player) ([]Game, error) {
 // Player Functions
 // ------------------------------
 
-func generateToken() string {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, 32)
-	for i := range b {
-		b[i] = letters[r.Intn(len(letters))]
-	}
-	return string(b)
-}
-
-func DB_Create_Player(name string) (string, error) {
-	slog.Debug("Create User", "name", name)
-	secretToken := generateToken()
+// DB_Create_Player creates a player of the owner (0 for none) together with
+// its first API token and returns the player id and the token. The token
+// itself is not stored, only its hash. Taken names return ErrNameTaken.
+func DB_Create_Player(name string, ownerID int) (int, string, error) {
+	slog.Debug("Create User", "name", name, "owner", ownerID)
 
 	db, err := Db_open()
-	defer db.Close()
 	if err != nil {
 		slog.Error("Error opening database during player creation", "error", err)
-		return "", err
+		return 0, "", err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	err = tx.QueryRow("SELECT COUNT(*) FROM Player WHERE Name = ? COLLATE NOCASE", name).Scan(&taken)
+	if err != nil {
+		return 0, "", err
+	}
+	if taken > 0 {
+		return 0, "", ErrNameTaken
 	}
 
-	_, err = db.Exec("INSERT INTO Player (Name, SecretToken, Elo) VALUES (?, ?, ?)", name, secretToken, 1000)
+	result, err := tx.Exec("INSERT INTO Player (Name, SecretToken, Elo, OwnerID) VALUES (?, '', ?, ?)", name, START_ELO, ownerID)
 	if err != nil {
 		slog.Error("Error inserting new player to db", "error", err)
-		return "", err
+		return 0, "", err
+	}
+	playerID, err := result.LastInsertId()
+	if err != nil {
+		return 0, "", err
+	}
+
+	_, err = insertVersion(tx, int(playerID), "", START_ELO)
+	if err != nil {
+		slog.Error("Error inserting version of new player", "error", err)
+		return 0, "", err
 	}
 
-	return secretToken, nil
+	token, _, err := insertToken(tx, int(playerID))
+	if err != nil {
+		slog.Error("Error inserting token of new player", "error", err)
+		return 0, "", err
+	}
+	return int(playerID), token, tx.Commit()
 }
 
 func reconstruct_history(db *sql.DB, playerID int) ([]HistoryEntry, error) {
@@ -383,10 +518,7 @@ func DB_Get_Player(id int) (*Player, error) {
 		return nil, err
 	}
 
-	row := db.QueryRow("SELECT * FROM Player WHERE ID = ?", id)
-
-	db_player := DB_Player{}
-	err = row.Scan(&db_player.ID, &db_player.Name, &db_player.SecretToken, &db_player.CurrentElo)
+	db_player, err := scanPlayer(db.QueryRow("SELECT "+DB_PLAYER_COLUMNS+" FROM Player WHERE ID = ?", id))
 	if err != nil {
 		return nil, err
 	}
@@ -397,13 +529,7 @@ func DB_Get_Player(id int) (*Player, error) {
 		return nil, err
 	}
 
-	player := Player{
-		ID:          db_player.ID,
-		Name:        db_player.Name,
-		SecretToken: db_player.SecretToken,
-		CurrentElo:  db_player.CurrentElo,
-		GameHistory: history,
-	}
+	player := db_player.toPlayer(history)
 	return &player, nil
 }
 
@@ -414,33 +540,32 @@ func DB_Get_Players() ([]Player, error) {
 		return nil, err
 	}
 
-	rows, err := db.Query("SELECT * FROM Player")
+	rows, err := db.Query("SELECT " + DB_PLAYER_COLUMNS + " FROM Player")
 	if err != nil {
 		return nil, err
 	}
 
 	players := make([]Player, 0)
 	for rows.Next() {
-		player := Player{}
-		err = rows.Scan(&player.ID, &player.Name, &player.SecretToken, &player.CurrentElo)
+		db_player, err := scanPlayer(rows)
 		if err != nil {
 			slog.Error("Error scanning player", "error", err)
 		}
 
 		// reconstruct game history
-		history, err := reconstruct_history(db, player.ID)
+		history, err := reconstruct_history(db, db_player.ID)
 		if err != nil {
 			slog.Error("Error reconstructing history", "error", err)
 		}
-		player.GameHistory = history
-		players = append(players, player)
+		players = append(players, db_player.toPlayer(history))
 	}
 
 	return players, nil
 }
 
+// DB_Get_Player_by_Token returns the player of a valid (not revoked) token.
+// Unknown and revoked tokens return sql.ErrNoRows.
 func DB_Get_Player_by_Token(token string) (*Player, error) {
-	slog.Debug("Get Player by Token", "token", token)
 	db, err := Db_open()
 	if err != nil {
 		slog.Error("Error opening database during player lookup (by token)", "error", err)
@@ -448,11 +573,14 @@ func DB_Get_Player_by_Token(token string) (*Player, error) {
 	}
 	defer db.Close()
 
-	row := db.QueryRow("SELECT * FROM Player WHERE SecretToken = ?", token)
-	db_player := DB_Player{}
-	err = row.Scan(&db_player.ID, &db_player.Name, &db_player.SecretToken, &db_player.CurrentElo)
+	row := db.QueryRow(`SELECT p.ID, p.Name, p.Elo, p.OwnerID, p.RetiredAt, p.TeamID FROM Player p
+		JOIN ApiToken t ON t.PlayerID = p.ID
+		WHERE t.TokenHash = ? AND t.RevokedAt = 0`, hashToken(token))
+	db_player, err := scanPlayer(row)
 	if err != nil {
-		slog.Error("Error opening database during player lookup (by token)", "token", token, "error", err)
+		if err != sql.ErrNoRows {
+			slog.Error("Error during player lookup (by token)", "prefix", tokenPrefix(token), "error", err)
+		}
 		return nil, err
 	}
 
@@ -473,199 +601,1287 @@ func DB_Get_Player_by_Token(token string) (*Player, error) {
 		history = append(history, db_history)
 	}
 
-	player := Player{
-		ID:          db_player.ID,
-		Name:        db_player.Name,
-		SecretToken: db_player.SecretToken,
-		CurrentElo:  db_player.CurrentElo,
-		GameHistory: history,
-	}
+	player := db_player.toPlayer(history)
 	return &player, nil
 }
 
-func DB_update_Elo_and_History(playerOneID int, playerTwoID int, outcome int, hist1 *HistoryEntry, hist2 *HistoryEntry) error {
+// ------------------------------
+// Token Functions
+// ------------------------------
+
+// insertToken generates a new token of the player and stores its hash. It
+// returns the token and the id of the stored row.
+func insertToken(tx *sql.Tx, playerID int) (string, int, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", 0, err
+	}
+	result, err := tx.Exec("INSERT INTO ApiToken (PlayerID, TokenHash, Prefix, CreatedAt) VALUES (?, ?, ?, ?)",
+		playerID, hashToken(token), tokenPrefix(token), time.Now().Unix())
+	if err != nil {
+		return "", 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", 0, err
+	}
+	return token, int(id), nil
+}
+
+// DB_Create_Token creates an additional token of the player.
+func DB_Create_Token(playerID int) (string, int, error) {
 	db, err := Db_open()
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 	defer db.Close()
 
-	transaction, err := db.Begin()
+	tx, err := db.Begin()
 	if err != nil {
-		return err
+		return "", 0, err
 	}
-	var currentElo_1, currentElo_2 int
+	defer tx.Rollback()
 
-	// lookup elo of player 1
-	row := transaction.QueryRow("SELECT Elo FROM Player WHERE ID = ?", playerOneID)
-	err = row.Scan(&currentElo_1)
+	token, id, err := insertToken(tx, playerID)
 	if err != nil {
-		transaction.Rollback()
-		return err
+		return "", 0, err
 	}
+	return token, id, tx.Commit()
+}
 
-	// lookup elo of player 1
-	row = transaction.QueryRow("SELECT Elo FROM Player WHERE ID = ?", playerTwoID)
-	err = row.Scan(&currentElo_2)
+// DB_Rotate_Token revokes the given token and returns a new token of the same
+// player. Revoked or unknown tokens return sql.ErrNoRows.
+func DB_Rotate_Token(token string) (string, int, error) {
+	db, err := Db_open()
 	if err != nil {
-		transaction.Rollback()
-		return err
+		return "", 0, err
 	}
+	defer db.Close()
 
-	_, e1, e2 := CalculateEloUpdate(currentElo_1, currentElo_2, outcome)
-	// assert.True(success, "Elo should be updated")
+	tx, err := db.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
 
-	// update elo player 1
-	_, err = transaction.Exec("UPDATE Player SET Elo = ? WHERE ID = ?", e1, playerOneID)
+	var playerID int
+	err = tx.QueryRow("UPDATE ApiToken SET RevokedAt = ? WHERE TokenHash = ? AND RevokedAt = 0 RETURNING PlayerID",
+		time.Now().Unix(), hashToken(token)).Scan(&playerID)
 	if err != nil {
-		transaction.Rollback()
-		return err
+		return "", 0, err
 	}
 
-	// update elo player 2
-	_, err = transaction.Exec("UPDATE Player SET Elo = ? WHERE ID = ?", e2, playerTwoID)
+	newToken, id, err := insertToken(tx, playerID)
 	if err != nil {
-		transaction.Rollback()
-		return err
+		return "", 0, err
 	}
+	return newToken, id, tx.Commit()
+}
 
-	// update history player 1
-	_, err = transaction.Exec("INSERT INTO HistoryEntry (GameID, PlayerID, Win, Draw, Loss, Elo) VALUES (?, ?, ?, ?, ?, ?)", hist1.GameID, playerOneID, hist1.Win, hist1.Draw, hist1.Loss, e1)
+// DB_Revoke_Token revokes a token of the player. Tokens of other players and
+// tokens that are already revoked return sql.ErrNoRows.
+func DB_Revoke_Token(playerID int, tokenID int) error {
+	db, err := Db_open()
 	if err != nil {
-		transaction.Rollback()
 		return err
 	}
+	defer db.Close()
 
-	// update history player 2
-	_, err = transaction.Exec("INSERT INTO HistoryEntry (GameID, PlayerID, Win, Draw, Loss, Elo) VALUES (?, ?, ?, ?, ?, ?)", hist2.GameID, playerTwoID, hist2.Win, hist2.Draw, hist2.Loss, e2)
+	result, err := db.Exec("UPDATE ApiToken SET RevokedAt = ? WHERE ID = ? AND PlayerID = ? AND RevokedAt = 0",
+		time.Now().Unix(), tokenID, playerID)
 	if err != nil {
-		transaction.Rollback()
 		return err
 	}
-
-	transaction.Commit()
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
-//  ------------------------------
-// Match Finder
-//  ------------------------------
-
-func ensureGamesAreRunning() error {
-	// Begin transaction
+// DB_Token_Active reports whether the token of the hash exists and is not
+// revoked.
+func DB_Token_Active(tokenHash string) (bool, error) {
 	db, err := Db_open()
 	if err != nil {
-		slog.Error("Error opening database (ensure actives games)", "error", err)
-		return err
+		return false, err
 	}
 	defer db.Close()
 
-	tx, err := db.Begin()
+	var n int
+	err = db.QueryRow("SELECT COUNT(*) FROM ApiToken WHERE TokenHash = ? AND RevokedAt = 0", tokenHash).Scan(&n)
 	if err != nil {
-		slog.Error("Error starting transaction (ensure actives games)", "error", err)
-		return err
+		return false, err
 	}
-	defer tx.Rollback()
+	return n > 0, nil
+}
 
-	// Check existing games
-	rows, err := tx.Query(`
-SELECT
-    pairing.p1,
-    pairing.p2,
-    COALESCE(COUNT(DISTINCT g.ID), 0) as active
-FROM
-    (
-        SELECT
-            player1.id as p1,
-            player2.id as p2
-        FROM
-            Player player1
-            JOIN Player player2 ON player1.id < player2.id
-    ) pairing
-    LEFT JOIN Game g ON g.Outcome = 0 AND (
-        (
-            g.Player1ID = pairing.p1
-            AND g.Player2ID = pairing.p2
-        )
-        OR (
-            g.Player1ID = pairing.p2
-            AND g.Player2ID = pairing.p1
-        )
-    )
-GROUP BY pairing.p1, pairing.p2
-`)
+// DB_Get_Tokens lists the tokens of the player, including revoked ones.
+func DB_Get_Tokens(playerID int) ([]ApiToken, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
 
-	// rows, err := tx.Query("SELECT pairing, COUNT(*) as active_games FROM games WHERE status = 'running' GROUP BY pairing")
+	rows, err := db.Query("SELECT ID, Prefix, CreatedAt, RevokedAt FROM ApiToken WHERE PlayerID = ? ORDER BY ID", playerID)
 	if err != nil {
-		slog.Error("Error during query (ensure actives games)", "error", err)
-		return err
+		return nil, err
 	}
-	// TODO: defer rows.Close() for all rows
 	defer rows.Close()
 
-	type Pairing struct {
-		p1    int
-		p2    int
-		count int
+	tokens := make([]ApiToken, 0)
+	for rows.Next() {
+		token := ApiToken{}
+		err = rows.Scan(&token.ID, &token.Prefix, &token.CreatedAt, &token.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		token.Active = token.RevokedAt == 0
+		tokens = append(tokens, token)
 	}
+	return tokens, rows.Err()
+}
 
-	pairings := make([]Pairing, 0)
-	for rows.Next() {
-		var pairing Pairing = Pairing{}
+// DB_Hash_Legacy_Tokens moves the plain text tokens of players created
+// before tokens were hashed into ApiToken and clears them. The tokens keep
+// working.
+func DB_Hash_Legacy_Tokens() (int, error) {
+	db, err := Db_open()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
 
-		if err := rows.Scan(&pairing.p1, &pairing.p2, &pairing.count); err != nil {
-			slog.Error("Error scanning row (ensure actives games)", "error", err)
-			continue
-		}
-		pairings = append(pairings, pairing)
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	slog.Info("Recurring job, current number of parings", "parings", len(pairings))
+	rows, err := tx.Query("SELECT ID, SecretToken FROM Player WHERE SecretToken != ''")
+	if err != nil {
+		return 0, err
+	}
+	legacy := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var token string
+		err = rows.Scan(&id, &token)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		legacy[id] = token
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
 
-	// Create new games if needed
-	for _, pairing := range pairings {
-		if pairing.count >= GAME_LIMIT_PER_PAIR {
-			continue
+	now := time.Now().Unix()
+	for id, token := range legacy {
+		_, err = tx.Exec("INSERT OR IGNORE INTO ApiToken (PlayerID, TokenHash, Prefix, CreatedAt) VALUES (?, ?, ?, ?)",
+			id, hashToken(token), tokenPrefix(token), now)
+		if err != nil {
+			return 0, err
 		}
-		slog.Info("Pairing", "p1", pairing.p1, "p2", pairing.p2, "count", pairing.count)
+		_, err = tx.Exec("UPDATE Player SET SecretToken = '' WHERE ID = ?", id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(legacy), tx.Commit()
+}
 
-		for i := 0; i < GAME_LIMIT_PER_PAIR-pairing.count; i++ {
-			slog.Info("creating game", "i", i, "p1", pairing.p1, "p2", pairing.p2, "count", pairing.count)
-			var rows, cols int
-			switch rand.Intn(4) {
-			case 0:
-				rows, cols = 3, 3
-			case 1:
-				rows, cols = 5, 3
-			case 2:
-				rows, cols = 8, 8
-			case 3:
-				rows, cols = 16, 16
-			}
+// ------------------------------
+// Version Functions
+// ------------------------------
 
-			var id1, id2 int
-			switch rand.Intn(2) {
-			case 0:
-				id1, id2 = pairing.p1, pairing.p2
-			case 1:
-				id2, id1 = pairing.p1, pairing.p2
-			}
+// current version of the player given as parameter, for inserting games
+const DB_CURRENT_VERSION = "(SELECT CurrentVersionID FROM Player WHERE ID = ?)"
 
-			slog.Debug("(ensure active games) Create Game", "i", i, "player1_id", id1, "player2_id", id2, "rows", rows, "cols", cols)
+// insertVersion adds a version with the given rating and makes it the
+// current version of the player.
+func insertVersion(tx *sql.Tx, playerID int, version string, elo int) (int, error) {
+	result, err := tx.Exec("INSERT INTO PlayerVersion (PlayerID, Version, Elo, SeedElo, CreatedAt) VALUES (?, ?, ?, ?, ?)",
+		playerID, version, elo, elo, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("UPDATE Player SET CurrentVersionID = ?, Elo = ? WHERE ID = ?", id, elo, playerID)
+	return int(id), err
+}
+
+// DB_Set_Player_Version switches the player to the version. An unknown
+// version is created with the rating of the current version, a known version
+// continues its own track. It returns whether the version is new.
+func DB_Set_Player_Version(playerID int, version string) (*PlayerVersion, bool, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, false, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	created := false
+	var id int
+	err = tx.QueryRow("SELECT ID FROM PlayerVersion WHERE PlayerID = ? AND Version = ?", playerID, version).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		var elo int
+		err = tx.QueryRow("SELECT Elo FROM Player WHERE ID = ?", playerID).Scan(&elo)
+		if err != nil {
+			return nil, false, err
+		}
+		id, err = insertVersion(tx, playerID, version, elo)
+		if err != nil {
+			return nil, false, err
+		}
+		created = true
+	case err != nil:
+		return nil, false, err
+	default:
+		_, err = tx.Exec("UPDATE Player SET CurrentVersionID = ?, Elo = (SELECT Elo FROM PlayerVersion WHERE ID = ?) WHERE ID = ?", id, id, playerID)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	versions, err := queryVersions(tx, "v.ID = ?", id)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(versions) != 1 {
+		return nil, false, sql.ErrNoRows
+	}
+	return &versions[0], created, tx.Commit()
+}
+
+// queryVersions reads the versions matching the condition on "PlayerVersion v"
+// together with their results.
+func queryVersions(q interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, condition string, args ...any) ([]PlayerVersion, error) {
+	rows, err := q.Query(`SELECT v.ID, v.Version, v.Elo, v.SeedElo, v.CreatedAt, v.ID = p.CurrentVersionID,
+		COALESCE(SUM(h.Win), 0), COALESCE(SUM(h.Draw), 0), COALESCE(SUM(h.Loss), 0)
+		FROM PlayerVersion v JOIN Player p ON p.ID = v.PlayerID LEFT JOIN HistoryEntry h ON h.VersionID = v.ID
+		WHERE `+condition+` GROUP BY v.ID ORDER BY v.ID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]PlayerVersion, 0)
+	for rows.Next() {
+		v := PlayerVersion{}
+		err = rows.Scan(&v.ID, &v.Version, &v.Elo, &v.SeedElo, &v.CreatedAt, &v.Current, &v.Results.Wins, &v.Results.Draws, &v.Results.Losses)
+		if err != nil {
+			return nil, err
+		}
+		v.EloChange = v.Elo - v.SeedElo
+		v.Results.updateScore()
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// ------------------------------
+// Team Functions
+// ------------------------------
+
+// DB_Create_Team creates a team managed by the owner. Taken names return
+// ErrNameTaken.
+func DB_Create_Team(name string, managerID int) (*Team, error) {
+	joinCode, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	err = tx.QueryRow("SELECT COUNT(*) FROM Team WHERE Name = ? COLLATE NOCASE", name).Scan(&taken)
+	if err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, ErrNameTaken
+	}
+
+	team := &Team{Name: name, ManagerID: managerID, JoinCode: joinCode, CreatedAt: time.Now().Unix()}
+	result, err := tx.Exec("INSERT INTO Team (Name, ManagerID, JoinCode, CreatedAt) VALUES (?, ?, ?, ?)", team.Name, team.ManagerID, team.JoinCode, team.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	team.ID = int(id)
+	return team, tx.Commit()
+}
+
+// DB_Get_Managed_Teams returns the teams of the manager including their join
+// codes.
+func DB_Get_Managed_Teams(managerID int) ([]Team, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT ID, Name, ManagerID, JoinCode, CreatedAt FROM Team WHERE ManagerID = ? ORDER BY ID", managerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make([]Team, 0)
+	for rows.Next() {
+		team := Team{}
+		err = rows.Scan(&team.ID, &team.Name, &team.ManagerID, &team.JoinCode, &team.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+// DB_Join_Team moves the player to the team of the join code. Unknown codes
+// return sql.ErrNoRows.
+func DB_Join_Team(playerID int, joinCode string) (*Team, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	team := &Team{}
+	err = db.QueryRow("SELECT ID, Name, ManagerID, CreatedAt FROM Team WHERE JoinCode = ?", joinCode).
+		Scan(&team.ID, &team.Name, &team.ManagerID, &team.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("UPDATE Player SET TeamID = ? WHERE ID = ?", team.ID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// DB_Leave_Team removes the player from its team. Players of other teams
+// (if teamID is not 0) and players without team return sql.ErrNoRows.
+func DB_Leave_Team(playerID int, teamID int) error {
+	db, err := Db_open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.Exec("UPDATE Player SET TeamID = 0 WHERE ID = ? AND TeamID != 0 AND (? = 0 OR TeamID = ?)", playerID, teamID, teamID)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DB_Get_Team_Standings returns all teams with their aggregate rating and
+// their results against bots of other teams or without team, ordered by the
+// average rating of the active members.
+func DB_Get_Team_Standings() ([]TeamStanding, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT t.ID, t.Name, COUNT(p.ID), COALESCE(SUM(p.RetiredAt = 0), 0),
+		COALESCE(AVG(CASE WHEN p.RetiredAt = 0 THEN p.Elo END), 0), COALESCE(MAX(CASE WHEN p.RetiredAt = 0 THEN p.Elo END), 0)
+		FROM Team t LEFT JOIN Player p ON p.TeamID = t.ID GROUP BY t.ID`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := make([]TeamStanding, 0)
+	index := make(map[int]int)
+	for rows.Next() {
+		s := TeamStanding{}
+		err = rows.Scan(&s.ID, &s.Name, &s.Members, &s.ActiveMembers, &s.AverageElo, &s.BestElo)
+		if err != nil {
+			return nil, err
+		}
+		index[s.ID] = len(standings)
+		standings = append(standings, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// results against other teams, games between members do not count
+	rows, err = db.Query(`SELECT p.TeamID, ` + DB_RESULT_SUMS + `
+		FROM HistoryEntry h JOIN Game g ON g.ID = h.GameID
+		JOIN Player p ON p.ID = h.PlayerID JOIN Player o ON o.ID = ` + DB_OPPONENT_ID + `
+		WHERE p.TeamID != 0 AND o.TeamID != p.TeamID GROUP BY p.TeamID`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var teamID int
+		results := ResultCounts{}
+		err = rows.Scan(&teamID, &results.Wins, &results.Draws, &results.Losses)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[teamID]; ok {
+			standings[i].Results = results
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if (a.ActiveMembers > 0) != (b.ActiveMembers > 0) {
+			return a.ActiveMembers > 0
+		}
+		if a.AverageElo != b.AverageElo {
+			return a.AverageElo > b.AverageElo
+		}
+		return a.ID < b.ID
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+		standings[i].Results.updateScore()
+	}
+	return standings, nil
+}
+
+// DB_Get_Team_Members returns the bots of the team with their results,
+// ordered by rating.
+func DB_Get_Team_Members(teamID int) ([]TeamMember, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT p.ID, p.Name, p.Elo, p.RetiredAt != 0,
+		COALESCE(SUM(h.Win), 0), COALESCE(SUM(h.Draw), 0), COALESCE(SUM(h.Loss), 0)
+		FROM Player p LEFT JOIN HistoryEntry h ON h.PlayerID = p.ID
+		WHERE p.TeamID = ? GROUP BY p.ID ORDER BY p.Elo DESC, p.ID`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]TeamMember, 0)
+	for rows.Next() {
+		m := TeamMember{}
+		err = rows.Scan(&m.ID, &m.Name, &m.Elo, &m.Retired, &m.Results.Wins, &m.Results.Draws, &m.Results.Losses)
+		if err != nil {
+			return nil, err
+		}
+		m.Results.updateScore()
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// ------------------------------
+// Owner Functions
+// ------------------------------
+
+// DB_Create_Owner stores a new owner account. Taken usernames return
+// ErrNameTaken.
+func DB_Create_Owner(username string, passwordHash string) (*Owner, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	err = tx.QueryRow("SELECT COUNT(*) FROM Owner WHERE Username = ? COLLATE NOCASE", username).Scan(&taken)
+	if err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, ErrNameTaken
+	}
+
+	owner := &Owner{Username: username, CreatedAt: time.Now().Unix()}
+	result, err := tx.Exec("INSERT INTO Owner (Username, PasswordHash, CreatedAt) VALUES (?, ?, ?)", username, passwordHash, owner.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	owner.ID = int(id)
+	return owner, tx.Commit()
+}
+
+// DB_Get_Owner_by_Username returns the owner and its password hash, unknown
+// usernames return sql.ErrNoRows.
+func DB_Get_Owner_by_Username(username string) (*Owner, string, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer db.Close()
+
+	owner := &Owner{}
+	var passwordHash string
+	err = db.QueryRow("SELECT ID, Username, CreatedAt, PasswordHash FROM Owner WHERE Username = ? COLLATE NOCASE", username).
+		Scan(&owner.ID, &owner.Username, &owner.CreatedAt, &passwordHash)
+	if err != nil {
+		return nil, "", err
+	}
+	return owner, passwordHash, nil
+}
+
+// DB_Create_Owner_Session starts a session of the owner and returns the
+// session token. Only its hash is stored.
+func DB_Create_Owner_Session(ownerID int, duration time.Duration) (string, int64, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", 0, err
+	}
+
+	db, err := Db_open()
+	if err != nil {
+		return "", 0, err
+	}
+	defer db.Close()
+
+	now := time.Now()
+	expiresAt := now.Add(duration).Unix()
+	// expired sessions are removed whenever a new one starts
+	_, err = db.Exec("DELETE FROM OwnerSession WHERE ExpiresAt <= ?", now.Unix())
+	if err != nil {
+		return "", 0, err
+	}
+	_, err = db.Exec("INSERT INTO OwnerSession (OwnerID, TokenHash, CreatedAt, ExpiresAt) VALUES (?, ?, ?, ?)",
+		ownerID, hashToken(token), now.Unix(), expiresAt)
+	if err != nil {
+		return "", 0, err
+	}
+	return token, expiresAt, nil
+}
+
+// DB_Get_Owner_by_Session returns the owner of a session that has not
+// expired, otherwise sql.ErrNoRows.
+func DB_Get_Owner_by_Session(token string) (*Owner, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	owner := &Owner{}
+	err = db.QueryRow(`SELECT o.ID, o.Username, o.CreatedAt FROM Owner o
+		JOIN OwnerSession s ON s.OwnerID = o.ID
+		WHERE s.TokenHash = ? AND s.ExpiresAt > ?`, hashToken(token), time.Now().Unix()).
+		Scan(&owner.ID, &owner.Username, &owner.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+func DB_Delete_Owner_Session(token string) error {
+	db, err := Db_open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("DELETE FROM OwnerSession WHERE TokenHash = ?", hashToken(token))
+	return err
+}
+
+// DB_Get_Owner_Bots returns the bots of the owner with their results,
+// including retired bots.
+func DB_Get_Owner_Bots(ownerID int) ([]OwnedBot, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT p.ID, p.Name, p.Elo, p.RetiredAt,
+		COALESCE(SUM(h.Win), 0), COALESCE(SUM(h.Draw), 0), COALESCE(SUM(h.Loss), 0)
+		FROM Player p LEFT JOIN HistoryEntry h ON h.PlayerID = p.ID
+		WHERE p.OwnerID = ? GROUP BY p.ID ORDER BY p.ID`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bots := make([]OwnedBot, 0)
+	for rows.Next() {
+		bot := OwnedBot{}
+		err = rows.Scan(&bot.ID, &bot.Name, &bot.Elo, &bot.RetiredAt, &bot.Results.Wins, &bot.Results.Draws, &bot.Results.Losses)
+		if err != nil {
+			return nil, err
+		}
+		bot.Retired = bot.RetiredAt != 0
+		bot.Results.updateScore()
+		bots = append(bots, bot)
+	}
+	return bots, rows.Err()
+}
+
+// DB_Retire_Player retires a bot of the owner and revokes its tokens. Bots of
+// other owners and retired bots return sql.ErrNoRows.
+func DB_Retire_Player(ownerID int, playerID int) error {
+	db, err := Db_open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	result, err := tx.Exec("UPDATE Player SET RetiredAt = ? WHERE ID = ? AND OwnerID = ? AND RetiredAt = 0", now, playerID, ownerID)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec("UPDATE ApiToken SET RevokedAt = ? WHERE PlayerID = ? AND RevokedAt = 0", now, playerID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GameParticipant describes a player of a game with the ratings of the
+// version that played it, before and after the game (EloAfter is 0 while the
+// game is running).
+type GameParticipant struct {
+	ID        int
+	Name      string
+	EloBefore int
+	EloAfter  int
+}
+
+func DB_Get_Game_Participants(game *Game) ([2]GameParticipant, error) {
+	participants := [2]GameParticipant{}
+
+	db, err := Db_open()
+	if err != nil {
+		return participants, err
+	}
+	defer db.Close()
+
+	for i, playerID := range []int{game.Player1ID, game.Player2ID} {
+		p := &participants[i]
+		p.ID = playerID
+
+		// version that played the game, like in DB_update_Elo_and_History
+		var versionID, versionElo, seedElo int
+		err = db.QueryRow(`SELECT p.Name, v.ID, v.Elo, v.SeedElo
+			FROM Game g JOIN Player p ON p.ID = ?
+			JOIN PlayerVersion v ON v.ID = CASE
+				WHEN p.ID = g.Player1ID AND g.Player1VersionID != 0 THEN g.Player1VersionID
+				WHEN p.ID = g.Player2ID AND g.Player2VersionID != 0 THEN g.Player2VersionID
+				ELSE p.CurrentVersionID END
+			WHERE g.ID = ?`, playerID, game.ID).Scan(&p.Name, &versionID, &versionElo, &seedElo)
+		if err != nil {
+			slog.Error("Error querying participant", "gameID", game.ID, "playerID", playerID, "error", err)
+			return participants, err
+		}
+
+		var entryID int
+		err = db.QueryRow("SELECT ID, Elo FROM HistoryEntry WHERE PlayerID = ? AND GameID = ?", playerID, game.ID).Scan(&entryID, &p.EloAfter)
+		if err == sql.ErrNoRows {
+			// game is still running (or unrated)
+			p.EloBefore = versionElo
+			continue
+		} else if err != nil {
+			return participants, err
+		}
+
+		err = db.QueryRow("SELECT Elo FROM HistoryEntry WHERE VersionID = ? AND ID < ? ORDER BY ID DESC LIMIT 1", versionID, entryID).Scan(&p.EloBefore)
+		if err == sql.ErrNoRows {
+			p.EloBefore = seedElo
+		} else if err != nil {
+			return participants, err
+		}
+	}
+
+	return participants, nil
+}
+
+// DB_update_Elo_and_History rates the versions of both players that played
+// the game and returns the rating changes. The rating of a player follows its
+// current version, results of older versions only change their own track.
+func DB_update_Elo_and_History(playerOneID int, playerTwoID int, outcome int, hist1 *HistoryEntry, hist2 *HistoryEntry) ([2]RatingChange, error) {
+	changes := [2]RatingChange{}
+
+	db, err := Db_open()
+	if err != nil {
+		return changes, err
+	}
+	defer db.Close()
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return changes, err
+	}
+	var versionID_1, versionID_2 int
+	var currentElo_1, currentElo_2 int
+
+	// lookup versions of the game, games created before the player had
+	// versions count for the current version
+	row := transaction.QueryRow(`SELECT
+		CASE WHEN g.Player1VersionID != 0 THEN g.Player1VersionID ELSE p1.CurrentVersionID END,
+		CASE WHEN g.Player2VersionID != 0 THEN g.Player2VersionID ELSE p2.CurrentVersionID END
+		FROM Game g JOIN Player p1 ON p1.ID = ? JOIN Player p2 ON p2.ID = ?
+		WHERE g.ID = ?`, playerOneID, playerTwoID, hist1.GameID)
+	err = row.Scan(&versionID_1, &versionID_2)
+	if err != nil {
+		transaction.Rollback()
+		return changes, err
+	}
+
+	// lookup elo of version 1
+	row = transaction.QueryRow("SELECT Elo FROM PlayerVersion WHERE ID = ?", versionID_1)
+	err = row.Scan(&currentElo_1)
+	if err != nil {
+		transaction.Rollback()
+		return changes, err
+	}
+
+	// lookup elo of version 2
+	row = transaction.QueryRow("SELECT Elo FROM PlayerVersion WHERE ID = ?", versionID_2)
+	err = row.Scan(&currentElo_2)
+	if err != nil {
+		transaction.Rollback()
+		return changes, err
+	}
+
+	_, e1, e2 := CalculateEloUpdate(currentElo_1, currentElo_2, outcome)
+	// assert.True(success, "Elo should be updated")
+
+	for _, update := range []struct{ playerID, versionID, elo int }{
+		{playerOneID, versionID_1, e1},
+		{playerTwoID, versionID_2, e2},
+	} {
+		_, err = transaction.Exec("UPDATE PlayerVersion SET Elo = ? WHERE ID = ?", update.elo, update.versionID)
+		if err != nil {
+			transaction.Rollback()
+			return changes, err
+		}
+		_, err = transaction.Exec("UPDATE Player SET Elo = ? WHERE ID = ? AND CurrentVersionID = ?", update.elo, update.playerID, update.versionID)
+		if err != nil {
+			transaction.Rollback()
+			return changes, err
+		}
+	}
+
+	// update history player 1
+	_, err = transaction.Exec("INSERT INTO HistoryEntry (GameID, PlayerID, Win, Draw, Loss, Elo, VersionID) VALUES (?, ?, ?, ?, ?, ?, ?)", hist1.GameID, playerOneID, hist1.Win, hist1.Draw, hist1.Loss, e1, versionID_1)
+	if err != nil {
+		transaction.Rollback()
+		return changes, err
+	}
+
+	// update history player 2
+	_, err = transaction.Exec("INSERT INTO HistoryEntry (GameID, PlayerID, Win, Draw, Loss, Elo, VersionID) VALUES (?, ?, ?, ?, ?, ?, ?)", hist2.GameID, playerTwoID, hist2.Win, hist2.Draw, hist2.Loss, e2, versionID_2)
+	if err != nil {
+		transaction.Rollback()
+		return changes, err
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		return changes, err
+	}
+
+	changes[0] = RatingChange{PlayerID: playerOneID, EloBefore: currentElo_1, EloAfter: e1}
+	changes[1] = RatingChange{PlayerID: playerTwoID, EloBefore: currentElo_2, EloAfter: e2}
+	return changes, nil
+}
+
+//  ------------------------------
+// Match Finder
+//  ------------------------------
+
+func ensureGamesAreRunning() error {
+	// Begin transaction
+	db, err := Db_open()
+	if err != nil {
+		slog.Error("Error opening database (ensure actives games)", "error", err)
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction (ensure actives games)", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Check existing games
+	rows, err := tx.Query(`
+SELECT
+    pairing.p1,
+    pairing.p2,
+    COALESCE(COUNT(DISTINCT g.ID), 0) as active
+FROM
+    (
+        SELECT
+            player1.id as p1,
+            player2.id as p2
+        FROM
+            Player player1
+            JOIN Player player2 ON player1.id < player2.id
+        WHERE
+            player1.RetiredAt = 0
+            AND player2.RetiredAt = 0
+            AND (? OR player1.TeamID = 0 OR player1.TeamID != player2.TeamID)
+    ) pairing
+    LEFT JOIN Game g ON g.Outcome = 0 AND (
+        (
+            g.Player1ID = pairing.p1
+            AND g.Player2ID = pairing.p2
+        )
+        OR (
+            g.Player1ID = pairing.p2
+            AND g.Player2ID = pairing.p1
+        )
+    )
+GROUP BY pairing.p1, pairing.p2
+`, allowSameTeamGames)
+
+	// rows, err := tx.Query("SELECT pairing, COUNT(*) as active_games FROM games WHERE status = 'running' GROUP BY pairing")
+	if err != nil {
+		slog.Error("Error during query (ensure actives games)", "error", err)
+		return err
+	}
+	// TODO: defer rows.Close() for all rows
+	defer rows.Close()
+
+	type Pairing struct {
+		p1    int
+		p2    int
+		count int
+	}
+
+	pairings := make([]Pairing, 0)
+	for rows.Next() {
+		var pairing Pairing = Pairing{}
+
+		if err := rows.Scan(&pairing.p1, &pairing.p2, &pairing.count); err != nil {
+			slog.Error("Error scanning row (ensure actives games)", "error", err)
+			continue
+		}
+		pairings = append(pairings, pairing)
+	}
+
+	slog.Info("Recurring job, current number of parings", "parings", len(pairings))
+
+	// Create new games if needed
+	created := make([]*Game, 0)
+	for _, pairing := range pairings {
+		if pairing.count >= GAME_LIMIT_PER_PAIR {
+			continue
+		}
+		slog.Info("Pairing", "p1", pairing.p1, "p2", pairing.p2, "count", pairing.count)
+
+		for i := 0; i < GAME_LIMIT_PER_PAIR-pairing.count; i++ {
+			slog.Info("creating game", "i", i, "p1", pairing.p1, "p2", pairing.p2, "count", pairing.count)
+			rows, cols := boardCatalog.Pick()
+
+			var id1, id2 int
+			switch rand.Intn(2) {
+			case 0:
+				id1, id2 = pairing.p1, pairing.p2
+			case 1:
+				id2, id1 = pairing.p1, pairing.p2
+			}
 
-			_, err := tx.Exec("INSERT INTO Game (Player1ID, Player2ID, Outcome, Rows, Cols) VALUES (?, ?, ?, ?, ?)",
+			slog.Debug("(ensure active games) Create Game", "i", i, "player1_id", id1, "player2_id", id2, "rows", rows, "cols", cols)
+
+			createdAt := time.Now().Unix()
+			result, err := tx.Exec("INSERT INTO Game (Player1ID, Player2ID, Outcome, Rows, Cols, CreatedAt, Rules, Player1VersionID, Player2VersionID) VALUES (?, ?, ?, ?, ?, ?, ?, "+DB_CURRENT_VERSION+", "+DB_CURRENT_VERSION+")",
 				id1,
 				id2,
 				0,
 				rows,
-				cols)
+				cols,
+				createdAt,
+				defaultRules.String(),
+				id1,
+				id2)
 
 			if err != nil {
 				slog.Error("Error inserting new game to db", "error", err)
+				continue
+			}
+
+			id, err := result.LastInsertId()
+			if err != nil {
+				slog.Error("Error reading id after game insertion", "error", err)
+				continue
 			}
+
+			state := NewGameState(rows, cols)
+			state.Rules = defaultRules
+			created = append(created, &Game{
+				ID:        int(id),
+				Player1ID: id1,
+				Player2ID: id2,
+				Outcome:   0,
+				CreatedAt: createdAt,
+				GameState: &state,
+			})
 		}
 	}
 
 	// Commit transaction
-	return tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	for _, game := range created {
+		activeGames.Update(game)
+		events.publishGameStart(game)
+	}
+	return nil
+}
+
+//  ------------------------------
+// Statistics
+//  ------------------------------
+
+// aggregated results of the rated games, for queries over "HistoryEntry h"
+const DB_RESULT_SUMS = "SUM(h.Win), SUM(h.Draw), SUM(h.Loss)"
+
+// opponent of the history entry's player in "Game g"
+const DB_OPPONENT_ID = "CASE WHEN g.Player1ID = h.PlayerID THEN g.Player2ID ELSE g.Player1ID END"
+
+func DB_Find_Player_IDs_by_Name(name string) ([]int, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT ID FROM Player WHERE Name = ? ORDER BY ID", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DB_Get_Player_Profile fails with sql.ErrNoRows for unknown players.
+func DB_Get_Player_Profile(playerID int) (*PlayerProfile, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	profile := &PlayerProfile{
+		Rating:      make([]RatingPoint, 0),
+		ByBoard:     make([]BoardStats, 0),
+		ByColor:     make([]ColorStats, 0),
+		Opponents:   make([]OpponentStats, 0),
+		RecentGames: make([]RecentGame, 0),
+	}
+	err = db.QueryRow("SELECT ID, Name, Elo FROM Player WHERE ID = ?", playerID).Scan(&profile.ID, &profile.Name, &profile.CurrentElo)
+	if err != nil {
+		return nil, err
+	}
+
+	// rating over time
+	rows, err := db.Query("SELECT h.GameID, h.Elo, g.EndedAt FROM HistoryEntry h JOIN Game g ON g.ID = h.GameID WHERE h.PlayerID = ? ORDER BY h.ID", playerID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		point := RatingPoint{}
+		err = rows.Scan(&point.GameID, &point.Elo, &point.EndedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		profile.Rating = append(profile.Rating, point)
+	}
+	rows.Close()
+
+	// results by board size, their sum are the total results
+	rows, err = db.Query("SELECT g.Rows, g.Cols, "+DB_RESULT_SUMS+" FROM HistoryEntry h JOIN Game g ON g.ID = h.GameID WHERE h.PlayerID = ? GROUP BY g.Rows, g.Cols ORDER BY g.Rows, g.Cols", playerID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		stats := BoardStats{}
+		err = rows.Scan(&stats.Rows, &stats.Cols, &stats.Wins, &stats.Draws, &stats.Losses)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.updateScore()
+		profile.ByBoard = append(profile.ByBoard, stats)
+
+		profile.Results.Wins += stats.Wins
+		profile.Results.Draws += stats.Draws
+		profile.Results.Losses += stats.Losses
+	}
+	rows.Close()
+	profile.Results.updateScore()
+
+	// results by color
+	rows, err = db.Query("SELECT CASE WHEN g.Player1ID = h.PlayerID THEN 1 ELSE 2 END AS Color, "+DB_RESULT_SUMS+" FROM HistoryEntry h JOIN Game g ON g.ID = h.GameID WHERE h.PlayerID = ? GROUP BY Color ORDER BY Color", playerID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		stats := ColorStats{}
+		err = rows.Scan(&stats.Player, &stats.Wins, &stats.Draws, &stats.Losses)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.updateScore()
+		profile.ByColor = append(profile.ByColor, stats)
+	}
+	rows.Close()
+
+	// head to head against every opponent, most frequent opponents first
+	rows, err = db.Query("SELECT p.ID, p.Name, "+DB_RESULT_SUMS+" FROM HistoryEntry h JOIN Game g ON g.ID = h.GameID JOIN Player p ON p.ID = "+DB_OPPONENT_ID+
+		" WHERE h.PlayerID = ? GROUP BY p.ID ORDER BY COUNT(*) DESC, p.ID", playerID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		stats := OpponentStats{}
+		err = rows.Scan(&stats.OpponentID, &stats.OpponentName, &stats.Wins, &stats.Draws, &stats.Losses)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.updateScore()
+		profile.Opponents = append(profile.Opponents, stats)
+	}
+	rows.Close()
+
+	// average number of plies of finished games
+	var average sql.NullFloat64
+	err = db.QueryRow("SELECT AVG(Plies) FROM (SELECT COUNT(t.ID) AS Plies FROM Game g LEFT JOIN Turn t ON t.GameID = g.ID WHERE g.Outcome != 0 AND (g.Player1ID = ? OR g.Player2ID = ?) GROUP BY g.ID)",
+		playerID, playerID).Scan(&average)
+	if err != nil {
+		return nil, err
+	}
+	profile.AverageGameLength = average.Float64
+
+	// rating tracks of the versions
+	profile.Versions, err = queryVersions(db, "v.PlayerID = ?", playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// most recent games, including running ones
+	rows, err = db.Query(`SELECT g.ID, g.Player1ID, g.Outcome, g.Termination, g.Rows, g.Cols, g.EndedAt,
+		(SELECT COUNT(*) FROM Turn t WHERE t.GameID = g.ID), p.ID, p.Name,
+		COALESCE((SELECT v.Version FROM PlayerVersion v WHERE v.ID = CASE WHEN g.Player1ID = ? THEN g.Player1VersionID ELSE g.Player2VersionID END), '')
+		FROM Game g JOIN Player p ON p.ID = CASE WHEN g.Player1ID = ? THEN g.Player2ID ELSE g.Player1ID END
+		WHERE g.Player1ID = ? OR g.Player2ID = ? ORDER BY g.ID DESC LIMIT ?`,
+		playerID, playerID, playerID, playerID, PROFILE_RECENT_GAMES)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		game := RecentGame{}
+		var player1ID, outcome int
+		err = rows.Scan(&game.GameID, &player1ID, &outcome, &game.Termination, &game.Rows, &game.Cols, &game.EndedAt, &game.Plies, &game.OpponentID, &game.OpponentName, &game.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		game.Player = 2
+		if player1ID == playerID {
+			game.Player = 1
+		}
+		switch outcome {
+		case 0:
+			game.Result = "running"
+		case -1:
+			game.Result = "draw"
+		case game.Player:
+			game.Result = "win"
+		default:
+			game.Result = "loss"
+		}
+		profile.RecentGames = append(profile.RecentGames, game)
+	}
+	return profile, rows.Err()
+}
+
+// DB_Get_Head_To_Head fails with sql.ErrNoRows if one of the players does not
+// exist.
+func DB_Get_Head_To_Head(player1ID int, player2ID int) (*HeadToHead, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	h2h := &HeadToHead{ByBoard: make([]BoardStats, 0)}
+	ids := [2]int{player1ID, player2ID}
+	for i, p := range []*PlayerRef{&h2h.Player1, &h2h.Player2} {
+		err = db.QueryRow("SELECT ID, Name, Elo FROM Player WHERE ID = ?", ids[i]).Scan(&p.ID, &p.Name, &p.Elo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.Query("SELECT g.Rows, g.Cols, "+DB_RESULT_SUMS+" FROM HistoryEntry h JOIN Game g ON g.ID = h.GameID WHERE h.PlayerID = ? AND "+DB_OPPONENT_ID+" = ? GROUP BY g.Rows, g.Cols ORDER BY g.Rows, g.Cols",
+		player1ID, player2ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		stats := BoardStats{}
+		err = rows.Scan(&stats.Rows, &stats.Cols, &stats.Wins, &stats.Draws, &stats.Losses)
+		if err != nil {
+			return nil, err
+		}
+		stats.updateScore()
+		h2h.ByBoard = append(h2h.ByBoard, stats)
+
+		h2h.Results.Wins += stats.Wins
+		h2h.Results.Draws += stats.Draws
+		h2h.Results.Losses += stats.Losses
+	}
+	h2h.Results.updateScore()
+	h2h.ScoreInterval = scoreInterval(h2h.Results.Score, h2h.Results.Games)
+	return h2h, rows.Err()
+}
+
+// DB_Get_Result_Matrix returns the results between the n best rated players.
+func DB_Get_Result_Matrix(n int) (*ResultMatrix, error) {
+	db, err := Db_open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	matrix := &ResultMatrix{Players: make([]PlayerRef, 0), Results: make([][]ResultCounts, 0)}
+	rows, err := db.Query("SELECT ID, Name, Elo FROM Player ORDER BY Elo DESC, ID LIMIT ?", n)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int]int)
+	for rows.Next() {
+		p := PlayerRef{}
+		err = rows.Scan(&p.ID, &p.Name, &p.Elo)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[p.ID] = len(matrix.Players)
+		matrix.Players = append(matrix.Players, p)
+	}
+	rows.Close()
+
+	for range matrix.Players {
+		matrix.Results = append(matrix.Results, make([]ResultCounts, len(matrix.Players)))
+	}
+
+	rows, err = db.Query("SELECT h.PlayerID, "+DB_OPPONENT_ID+" AS Opponent, "+DB_RESULT_SUMS+
+		" FROM HistoryEntry h JOIN Game g ON g.ID = h.GameID WHERE h.PlayerID IN (SELECT ID FROM Player ORDER BY Elo DESC, ID LIMIT ?) GROUP BY h.PlayerID, Opponent", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var playerID, opponentID int
+		counts := ResultCounts{}
+		err = rows.Scan(&playerID, &opponentID, &counts.Wins, &counts.Draws, &counts.Losses)
+		if err != nil {
+			return nil, err
+		}
+		i, ok1 := index[playerID]
+		j, ok2 := index[opponentID]
+		if !ok1 || !ok2 {
+			// opponent outside of the top n
+			continue
+		}
+		counts.updateScore()
+		matrix.Results[i][j] = counts
+	}
+	return matrix, rows.Err()
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"html/template"
 	"log/slog"
 	"net/http"
 	"path/filepath"
@@ -8,8 +9,6 @@ import (
 
 // Serve static files with correct MIME types
 func ServeStatic(w http.ResponseWriter, r *http.Request) {
-	LogRequest(r)
-
 	filePath := "." + r.URL.Path // Static files are located in the ./static folder
 	ext := filepath.Ext(filePath)
 	slog.Debug("Serving static file", "path", filePath, "ext", ext)
@@ -28,35 +27,34 @@ func ServeStatic(w http.ResponseWriter, r *http.Request) {
 }
 
 func InitHttpHandler_Frontend_Handler() {
+	replayTemplate = template.Must(template.ParseFiles("./templates/replay.html"))
+
 	// Custom handler for static files
-	http.HandleFunc("GET /static/", ServeStatic)
+	mux.HandleFunc("GET /static/", ServeStatic)
 	// Custom handler for static files
-	http.HandleFunc("GET /favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("GET /favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./static/favicon.ico")
 	})
 
-	http.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/leaderboard", http.StatusSeeOther)
 	})
 
-	http.HandleFunc("GET /game", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
+	mux.HandleFunc("GET /game", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./templates/game.html")
 	})
 
-	http.HandleFunc("GET /games", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
+	mux.HandleFunc("GET /games", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./templates/games.html")
 	})
 
-	http.HandleFunc("GET /leaderboard", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
+	mux.HandleFunc("GET /leaderboard", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./templates/leaderboard.html")
 	})
 
-	http.HandleFunc("GET /player", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
+	mux.HandleFunc("GET /player", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./templates/player.html")
 	})
+
+	mux.HandleFunc("GET /replay/{id}", serveReplay)
 }
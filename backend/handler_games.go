@@ -1,36 +1,53 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var PAGE_SIZE = 10
 
+var (
+	ErrGameOver    = errors.New("game is already over")
+	ErrGameChanged = errors.New("game has changed meanwhile, try again")
+)
+
 type Game struct {
-	ID        int        `json:"id"`
-	Player1ID int        `json:"player1_id"`
-	Player2ID int        `json:"player2_id"`
-	Outcome   int        `json:"outcome"`    // -1: draw, 0: ongoing, 1: win playerOne, 2: win playerTwo
-	GameState *GameState `json:"game_state"` // Additional field to store the state of the game
+	ID          int        `json:"id"`
+	Player1ID   int        `json:"player1_id"`
+	Player2ID   int        `json:"player2_id"`
+	Outcome     int        `json:"outcome"`      // -1: draw, 0: ongoing, 1: win playerOne, 2: win playerTwo
+	CreatedAt   int64      `json:"created_at"`   // unix seconds
+	EndedAt     int64      `json:"ended_at"`     // unix seconds, 0 while the game is running
+	Termination string     `json:"termination"`  // see termination.go, "" while the game is running
+	DrawOffer   int        `json:"draw_offer"`   // player (1 or 2) with a pending draw offer, 0 for none
+	LastMoveAt  int64      `json:"last_move_at"` // unix seconds, 0 before the first move
+	Unrated     bool       `json:"unrated"`      // games from custom positions do not change the ratings
+	GameState   *GameState `json:"game_state"`   // Additional field to store the state of the game
 }
 
-func createGame(p1 Player, p2 Player) (*Game, error) {
-	var rows, cols int
-	switch rand.Intn(4) {
-	case 0:
-		rows, cols = 3, 3
-	case 1:
-		rows, cols = 5, 3
-	case 2:
-		rows, cols = 8, 8
-	case 3:
-		rows, cols = 16, 16
+// PlayerNumber returns 1 or 2 for the participants of the game, 0 otherwise.
+func (game *Game) PlayerNumber(playerID int) int {
+	switch playerID {
+	case game.Player1ID:
+		return 1
+	case game.Player2ID:
+		return 2
 	}
+	return 0
+}
+
+func createGame(p1 Player, p2 Player) (*Game, error) {
+	rows, cols := boardCatalog.Pick()
 
 	var id1, id2 int
 	switch rand.Intn(2) {
@@ -40,7 +57,10 @@ func createGame(p1 Player, p2 Player) (*Game, error) {
 		id2, id1 = p1.ID, p2.ID
 	}
 
-	id, err := DB_Create_Game(id1, id2, rows, cols)
+	state := NewGameState(rows, cols)
+	state.Rules = defaultRules
+
+	id, err := DB_Create_Game(id1, id2, &state)
 	if err != nil {
 		slog.Error("Error creating game", "error", err)
 		return nil, err
@@ -50,7 +70,22 @@ func createGame(p1 Player, p2 Player) (*Game, error) {
 		slog.Error("Error creating game", "id", id, "error", err)
 		return nil, err
 	}
+	activeGames.Update(game)
+	events.publishGameStart(game)
+
+	return game, err
+}
 
+// getGame returns the game from the active game cache and falls back to the
+// database for finished games. Unknown games fail with ERR_NOT_FOUND.
+func getGame(id int) (*Game, error) {
+	if game, ok := activeGames.Get(id); ok {
+		return game, nil
+	}
+	game, err := DB_Get_Game(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NewAPIError(ERR_NOT_FOUND, "Game not found")
+	}
 	return game, err
 }
 
@@ -61,7 +96,7 @@ func serveGames(w http.ResponseWriter, r *http.Request) {
 	}
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
-		http.Error(w, "Invalid page number", http.StatusBadRequest)
+		writeError(w, ERR_BAD_REQUEST, "Invalid page number")
 		return
 	}
 
@@ -71,9 +106,10 @@ func serveGames(w http.ResponseWriter, r *http.Request) {
 	games, err := DB_Get_Games(startIdx, endIdx)
 	if err != nil {
 		slog.Error("Error getting game state", "page", page, "error", err)
-		http.Error(w, "Game not found", http.StatusNotFound)
+		writeError(w, ERR_NOT_FOUND, "Game not found")
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(games)
 }
 
@@ -83,105 +119,207 @@ func serveGameState(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(idString)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
 	}
 
-	game, err := DB_Get_Game(id)
+	game, err := getGame(id)
 	if err != nil {
-		slog.Error("Error getting game state", "id", id, "error", err)
-		http.Error(w, "Game not found", http.StatusNotFound)
+		writeGameError(w, id, err)
 		return
 	}
 
 	slog.Debug("game pulled", "id", id, "game", game)
-	game.GameState.Display()
 
 	json.NewEncoder(w).Encode(game)
 }
 
-func applyAction(player Player, gameId int, action Turn) (bool, string) {
-	game, err := DB_Get_Game(gameId)
+// applyAction plays the action of the player, the errors are converted for
+// the client by apiError.
+func applyAction(player Player, gameId int, action Turn) error {
+	game, err := getGame(gameId)
 	if err != nil {
-		return false, err.Error()
+		return err
 	}
 
-	if (game.GameState.NextPlayer() == 1 && game.Player1ID != player.ID) ||
-		(game.GameState.NextPlayer() == 2 && game.Player2ID != player.ID) {
-		return false, "not your turn"
+	me := game.PlayerNumber(player.ID)
+	if me == 0 {
+		return NewAPIError(ERR_NOT_YOUR_GAME, "Not your game")
+	}
+	if game.Outcome != 0 {
+		return ErrGameOver
+	}
+	if game.GameState.NextPlayer() != me {
+		return ErrNotYourTurn
 	}
 
 	valid := game.GameState.applyAction(action)
 	if !valid {
-		return false, "invalid action (against the rules)"
+		return ErrIllegalMove
 	}
 
+	// moving declines a pending draw offer of the opponent
+	if game.DrawOffer != 0 && game.DrawOffer != game.PlayerNumber(player.ID) {
+		game.DrawOffer = 0
+	}
+
+	game.LastMoveAt = time.Now().Unix()
+
 	// update game outcome
 	if game.GameState.IsEnd() {
 		game.Outcome = game.GameState.GetWinner()
+		game.EndedAt = time.Now().Unix()
+		game.Termination = terminationReason(game.GameState)
+		game.DrawOffer = 0
 	}
 
 	err = DB_apply_action(action, game)
 	if err != nil {
-		return false, err.Error()
+		if errors.Is(err, ErrGameOver) {
+			activeGames.Evict(gameId)
+		}
+		return err
 	}
+	activeGames.Update(game)
+	events.publishMove(game, action)
 
 	if game.GameState.IsEnd() {
-		// update player elo and game histories
-		hist1 := &HistoryEntry{
-			GameID: game.ID,
-			Win:    game.Outcome == 1,
-			Draw:   game.Outcome == -1,
-			Loss:   game.Outcome == 2,
-			Elo:    0,
+		events.publishGameEnd(game)
+		if !game.Unrated {
+			updateRatings(game)
 		}
-		hist2 := &HistoryEntry{
-			GameID: game.ID,
-			Win:    game.Outcome == 2,
-			Draw:   game.Outcome == -1,
-			Loss:   game.Outcome == 1,
-			Elo:    0,
-		}
-		DB_update_Elo_and_History(game.Player1ID, game.Player2ID, game.Outcome, hist1, hist2)
 	}
 
-	return true, ""
+	return nil
+}
 
+// writeActionError writes the error of applyAction and logs unexpected ones.
+// writeGameError answers a failed lookup of a game, only missing games are
+// NOT_FOUND.
+func writeGameError(w http.ResponseWriter, gameID int, err error) {
+	apiErr := apiError(err)
+	if apiErr.Code == ERR_INTERNAL {
+		slog.Error("Error loading game", "id", gameID, "error", err)
+	}
+	writeAPIError(w, apiErr)
 }
 
-func servePerformActionBulk(w http.ResponseWriter, r *http.Request) {
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		http.Error(w, "Token is required for authorization.", http.StatusUnauthorized)
-		return
+func writeActionError(w http.ResponseWriter, gameID int, err error) {
+	apiErr := apiError(err)
+	if apiErr.Code == ERR_INTERNAL {
+		slog.Error("Error applying action", "id", gameID, "error", err)
+	}
+	writeAPIError(w, apiErr)
+}
+
+// endGame finishes a running game without a move and updates the ratings of
+// rated games.
+func endGame(game *Game, outcome int, termination string) error {
+	game.Outcome = outcome
+	game.EndedAt = time.Now().Unix()
+	game.Termination = termination
+	game.DrawOffer = 0
+
+	err := DB_End_Game(game)
+	if err != nil {
+		if errors.Is(err, ErrGameOver) {
+			activeGames.Evict(game.ID)
+		}
+		return err
+	}
+	activeGames.Update(game)
+	events.publishGameEnd(game)
+	if !game.Unrated {
+		updateRatings(game)
 	}
+	return nil
+}
 
-	// get player
-	player, err := DB_Get_Player_by_Token(token)
+// updateRatings updates the elo and game histories of both players of a
+// finished game.
+func updateRatings(game *Game) {
+	hist1 := &HistoryEntry{
+		GameID: game.ID,
+		Win:    game.Outcome == 1,
+		Draw:   game.Outcome == -1,
+		Loss:   game.Outcome == 2,
+		Elo:    0,
+	}
+	hist2 := &HistoryEntry{
+		GameID: game.ID,
+		Win:    game.Outcome == 2,
+		Draw:   game.Outcome == -1,
+		Loss:   game.Outcome == 1,
+		Elo:    0,
+	}
+	changes, err := DB_update_Elo_and_History(game.Player1ID, game.Player2ID, game.Outcome, hist1, hist2)
 	if err != nil {
-		msg := fmt.Sprint("Invalid token (%s)", err.Error())
-		http.Error(w, msg, http.StatusUnauthorized)
+		slog.Error("Error updating elo", "gameID", game.ID, "error", err)
+		return
 	}
+	for _, change := range changes {
+		change.GameID = game.ID
+		events.Publish(GLOBAL_EVENTS, EVENT_RATING, change)
+	}
+}
+
+// BulkAction is an action of POST /games/actions.
+type BulkAction struct {
+	GameID int  `json:"gameId"`
+	Action Turn `json:"action"`
+}
 
-	var actions []struct {
-		GameID int  `json:"gameId"`
-		Action Turn `json:"action"`
+// BulkActionResult holds the errors of the actions that failed by game id.
+type BulkActionResult struct {
+	Applied int               `json:"applied"`
+	Errors  map[int]*APIError `json:"errors"`
+}
+
+func servePerformActionBulk(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&actions)
+	var actions []BulkAction
+
+	err := json.NewDecoder(r.Body).Decode(&actions)
 	if err != nil {
-		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input")
 		return
 	}
 
-	msgs := make(map[int]string, 0)
+	// the errors are keyed by game id, so every game may appear only once
+	seen := make(map[int]bool, len(actions))
+	for _, a := range actions {
+		if seen[a.GameID] {
+			writeError(w, ERR_BAD_REQUEST, fmt.Sprintf("Duplicate action for game %d", a.GameID))
+			return
+		}
+		seen[a.GameID] = true
+	}
+
+	// the errors of the actions that failed, by game id
+	errs := make(map[int]*APIError, 0)
+	applied := 0
 
 	for _, a := range actions {
-		success, msg := applyAction(*player, a.GameID, a.Action)
-		if !success {
-			msgs[a.GameID] = msg
+		err := applyAction(*player, a.GameID, a.Action)
+		if err != nil {
+			errs[a.GameID] = apiError(err)
+			if errs[a.GameID].Code == ERR_INTERNAL {
+				slog.Error("Error applying action", "id", a.GameID, "error", err)
+			}
+			continue
 		}
+		applied++
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BulkActionResult{
+		Applied: applied,
+		Errors:  errs,
+	})
 }
 
 func servePerformAction(w http.ResponseWriter, r *http.Request) {
@@ -189,33 +327,25 @@ func servePerformAction(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(idString)
 
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
 		return
 	}
 
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		http.Error(w, "Token is required for authorization.", http.StatusUnauthorized)
+	player := authenticate(w, r)
+	if player == nil {
 		return
 	}
 
-	// get player
-	player, err := DB_Get_Player_by_Token(token)
-	if err != nil {
-		msg := fmt.Sprint("Invalid token (%s)", err.Error())
-		http.Error(w, msg, http.StatusUnauthorized)
-	}
-
 	var action Turn
 	err = json.NewDecoder(r.Body).Decode(&action)
 	if err != nil {
-		http.Error(w, "Invalid action (Parsing errors)", http.StatusBadRequest)
+		writeError(w, ERR_INVALID_JSON, "Invalid action (Parsing errors)")
 		return
 	}
 
-	success, msg := applyAction(*player, id, action)
-	if !success {
-		http.Error(w, msg, http.StatusBadRequest)
+	err = applyAction(*player, id, action)
+	if err != nil {
+		writeActionError(w, id, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -225,87 +355,450 @@ func serveActiveGames(w http.ResponseWriter, _ *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	activeGames, err := DB_Get_Active_Games()
+	if activeGames.Loaded() {
+		json.NewEncoder(w).Encode(activeGames.GetAll())
+		return
+	}
+
+	games, err := DB_Get_Active_Games()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, ERR_INTERNAL, "Internal server error")
 		return
 	}
-	json.NewEncoder(w).Encode(activeGames)
+	json.NewEncoder(w).Encode(games)
+}
+
+// ActiveGames are the running games of a player, split by the player to move.
+type ActiveGames struct {
+	MyTurn   []Game `json:"my_turn"`
+	Awaiting []Game `json:"awaiting"`
+}
+
+// activeGamesOf returns the running games of the player.
+func activeGamesOf(player *Player) (ActiveGames, error) {
+	myturn := make([]Game, 0)
+	awating := make([]Game, 0)
+
+	var games []Game
+	if activeGames.Loaded() {
+		games = activeGames.GetByPlayer(player.ID)
+	} else {
+		var err error
+		games, err = DB_Get_Active_Games_By_Player(player)
+		if err != nil {
+			return ActiveGames{}, err
+		}
+	}
+
+	for _, game := range games {
+		if (game.Player1ID == player.ID && game.GameState.NextPlayer() == 1) || (game.Player2ID == player.ID && game.GameState.NextPlayer() == 2) {
+			myturn = append(myturn, game)
+		} else {
+			awating = append(awating, game)
+		}
+	}
+	return ActiveGames{
+		MyTurn:   myturn,
+		Awaiting: awating,
+	}, nil
 }
 
 func serveActiveGamesUser(w http.ResponseWriter, r *http.Request) {
-	token := r.PathValue("userToken")
-	if token == "" {
-		http.Error(w, "Token is required for authorization.", http.StatusUnauthorized)
+	player := authenticate(w, r)
+	if player == nil {
 		return
 	}
 
-	// get player
-	player, err := DB_Get_Player_by_Token(token)
+	active, err := activeGamesOf(player)
 	if err != nil {
-		msg := fmt.Sprint("Invalid token (%s)", err.Error())
-		http.Error(w, msg, http.StatusUnauthorized)
+		slog.Error("Error getting active games", "player", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
 		return
 	}
 
-	myturn := make([]Game, 0)
-	awating := make([]Game, 0)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(active)
+}
+
+type CustomGameRequest struct {
+	OpponentID int     `json:"opponent_id"`
+	Position   string  `json:"position"`
+	Rules      *string `json:"rules"`  // optional, default: rules of the position or of the server
+	Player     int     `json:"player"` // 1 or 2 to choose a side, random otherwise
+}
+
+// serveCreateCustomGame creates a game between the requesting player and an
+// opponent, starting from a custom position.
+func serveCreateCustomGame(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
 
-	activeGames, err := DB_Get_Active_Games_By_Player(player)
+	var request CustomGameRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input")
 		return
 	}
 
-	for _, game := range activeGames {
-		if (game.Player1ID == player.ID && game.GameState.NextPlayer() == 1) || (game.Player2ID == player.ID && game.GameState.NextPlayer() == 2) {
-			myturn = append(myturn, game)
-		} else {
-			awating = append(awating, game)
+	if request.OpponentID == player.ID {
+		writeError(w, ERR_BAD_REQUEST, "Can not play against yourself")
+		return
+	}
+	opponent, err := DB_Get_Player(request.OpponentID)
+	if err != nil {
+		writeError(w, ERR_NOT_FOUND, "Opponent not found")
+		return
+	}
+	if opponent.Retired {
+		writeError(w, ERR_BOT_RETIRED, "Opponent is retired")
+		return
+	}
+
+	state, err := NewGameStateFromPosition(request.Position)
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, "Invalid start position: "+err.Error())
+		return
+	}
+	if request.Rules != nil {
+		state.Rules, err = ParseRuleVariant(*request.Rules)
+		if err != nil {
+			writeError(w, ERR_BAD_REQUEST, "Invalid rules: "+err.Error())
+			return
 		}
+	} else if len(strings.Fields(request.Position)) == 3 {
+		state.Rules = defaultRules
+	}
+	// the rules decide whether the position is already over
+	err = validateStartPosition(state)
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, "Invalid start position: "+err.Error())
+		return
+	}
+
+	id1, id2 := player.ID, opponent.ID
+	if request.Player == 2 || (request.Player != 1 && rand.Intn(2) == 1) {
+		id1, id2 = id2, id1
+	}
+
+	id, err := DB_Create_Game(id1, id2, state)
+	if err != nil {
+		writeError(w, ERR_INTERNAL, "Error creating game")
+		return
+	}
+	game, err := DB_Get_Game(id)
+	if err != nil {
+		writeError(w, ERR_INTERNAL, "Error creating game")
+		return
 	}
+	activeGames.Update(game)
+	events.publishGameStart(game)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(struct {
-		MyTurn   []Game `json:"my_turn"`
-		Awaiting []Game `json:"awaiting"`
-	}{
-		MyTurn:   myturn,
-		Awaiting: awating,
-	},
-	)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(game)
 }
 
-func InitHttpHandler_Game_Handler() {
+// GamePosition is the position of a game in text form.
+type GamePosition struct {
+	ID int `json:"id"`
+	PositionView
+}
 
-	http.HandleFunc("GET /games/all", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveGames(w, r)
-	})
+func serveGamePosition(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
 
-	http.HandleFunc("GET /game/{id}/state", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveGameState(w, r)
-	})
+	game, err := getGame(id)
+	if err != nil {
+		writeGameError(w, id, err)
+		return
+	}
 
-	http.HandleFunc("POST /game/{id}/action", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		servePerformAction(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GamePosition{
+		ID:           game.ID,
+		PositionView: game.GameState.PositionView(),
 	})
+}
 
-	http.HandleFunc("POST /games/actions", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		servePerformActionBulk(w, r)
-	})
+// servePerformMove is the text variant of servePerformAction: the body holds
+// a single move in algebraic notation, e.g. "a2-a3".
+func servePerformMove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
 
-	http.HandleFunc("GET /games/active", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveActiveGames(w, r)
-	})
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+	if err != nil {
+		writeError(w, ERR_ILLEGAL_MOVE, "Invalid move")
+		return
+	}
+
+	game, err := getGame(id)
+	if err != nil {
+		writeGameError(w, id, err)
+		return
+	}
+
+	action, err := game.GameState.ParseMove(string(body))
+	if err != nil {
+		writeError(w, ERR_ILLEGAL_MOVE, err.Error())
+		return
+	}
+
+	err = applyAction(*player, id, action)
+	if err != nil {
+		writeActionError(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveAnalyzePosition parses a position (?pos=), optionally plays a comma
+// separated list of moves (?moves=) and returns the resulting position.
+func serveAnalyzePosition(w http.ResponseWriter, r *http.Request) {
+	state, err := ParsePosition(r.URL.Query().Get("pos"))
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, "Invalid position: "+err.Error())
+		return
+	}
+
+	if moves := r.URL.Query().Get("moves"); moves != "" {
+		for i, move := range strings.Split(moves, ",") {
+			if state.IsEnd() {
+				writeError(w, ERR_BAD_REQUEST, fmt.Sprintf("Move %d (%s) played after the end of the game", i+1, move))
+				return
+			}
+			action, err := state.ParseMove(move)
+			if err != nil {
+				writeError(w, ERR_BAD_REQUEST, err.Error())
+				return
+			}
+			state.MakeMove(action)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state.PositionView())
+}
+
+func serveGameRecord(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
+
+	game, err := getGame(id)
+	if err != nil {
+		writeGameError(w, id, err)
+		return
+	}
+
+	participants, err := DB_Get_Game_Participants(game)
+	if err != nil {
+		writeError(w, ERR_INTERNAL, "Error loading players of the game")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"rlarena-game-%d.pgn\"", game.ID))
+	fmt.Fprint(w, NewGameRecord(game, participants).String())
+}
+
+// serveImportRecords validates a bulk of game records by replaying them. The
+// records are not stored, the reconstructed games are returned instead.
+type ImportResult struct {
+	Index     int               `json:"index"`
+	Headers   map[string]string `json:"headers"`
+	Valid     bool              `json:"valid"`
+	Error     string            `json:"error,omitempty"`
+	GameState *GameState        `json:"game_state,omitempty"`
+}
+
+type ImportResults struct {
+	Total   int            `json:"total"`
+	Valid   int            `json:"valid"`
+	Records []ImportResult `json:"records"`
+}
+
+func serveImportRecords(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, "Error reading records")
+		return
+	}
 
-	http.HandleFunc("GET /games/active/{userToken}", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveActiveGamesUser(w, r)
+	records, err := ParseRecords(string(body))
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, "Invalid records: "+err.Error())
+		return
+	}
+
+	results := make([]ImportResult, 0, len(records))
+	valid := 0
+	for i, record := range records {
+		result := ImportResult{Index: i, Headers: record.Headers}
+		state, err := record.Replay()
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Valid = true
+			result.GameState = state
+			valid++
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ImportResults{
+		Total:   len(records),
+		Valid:   valid,
+		Records: results,
 	})
+}
+
+// participantGame reads the game of the request path and checks that the
+// player of the token takes part in it. It returns the game and the player
+// number, or writes the error response and returns nil.
+func participantGame(w http.ResponseWriter, r *http.Request) (*Game, int) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return nil, 0
+	}
+
+	player := authenticate(w, r)
+	if player == nil {
+		return nil, 0
+	}
+
+	game, err := getGame(id)
+	if err != nil {
+		writeGameError(w, id, err)
+		return nil, 0
+	}
+
+	me := game.PlayerNumber(player.ID)
+	if me == 0 {
+		writeError(w, ERR_NOT_YOUR_GAME, "Not your game")
+		return nil, 0
+	}
+	if game.Outcome != 0 {
+		writeError(w, ERR_GAME_OVER, ErrGameOver.Error())
+		return nil, 0
+	}
+	return game, me
+}
+
+func writeGameEndError(w http.ResponseWriter, game *Game, err error) {
+	apiErr := apiError(err)
+	if apiErr.Code == ERR_INTERNAL {
+		slog.Error("Error ending game", "id", game.ID, "error", err)
+	}
+	writeAPIError(w, apiErr)
+}
+
+// serveResign ends the game as a loss for the resigning player.
+func serveResign(w http.ResponseWriter, r *http.Request) {
+	game, me := participantGame(w, r)
+	if game == nil {
+		return
+	}
+
+	err := endGame(game, 3-me, TERMINATION_RESIGNATION)
+	if err != nil {
+		writeGameEndError(w, game, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(game)
+}
+
+// serveDrawOffer offers a draw to the opponent, or accepts the pending offer
+// of the opponent. An offer stays valid until the opponent moves, declines
+// or accepts it.
+func serveDrawOffer(w http.ResponseWriter, r *http.Request) {
+	game, me := participantGame(w, r)
+	if game == nil {
+		return
+	}
+
+	switch game.DrawOffer {
+	case 3 - me:
+		err := endGame(game, -1, TERMINATION_AGREEMENT)
+		if err != nil {
+			writeGameEndError(w, game, err)
+			return
+		}
+	case 0:
+		game.DrawOffer = me
+		err := DB_Set_Draw_Offer(game)
+		if err != nil {
+			writeGameEndError(w, game, err)
+			return
+		}
+		activeGames.Update(game)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(game)
+}
+
+func serveDrawDecline(w http.ResponseWriter, r *http.Request) {
+	game, me := participantGame(w, r)
+	if game == nil {
+		return
+	}
+	if game.DrawOffer != 3-me {
+		writeError(w, ERR_NO_DRAW_OFFER, "no draw offer to decline")
+		return
+	}
+
+	game.DrawOffer = 0
+	err := DB_Set_Draw_Offer(game)
+	if err != nil {
+		writeGameEndError(w, game, err)
+		return
+	}
+	activeGames.Update(game)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(game)
+}
+
+func InitHttpHandler_Game_Handler() {
+
+	mux.HandleFunc("GET /games/all", deprecated("/games", serveGames))
+	mux.HandleFunc("GET /game/{id}/state", deprecated("/games/{id}", serveGameState))
+	mux.HandleFunc("POST /game/{id}/action", deprecated("/games/{id}/actions", servePerformAction))
+	mux.HandleFunc("GET /game/{id}/position", deprecated("/games/{id}/position", serveGamePosition))
+	mux.HandleFunc("POST /game/{id}/move", deprecated("/games/{id}/moves", servePerformMove))
+	mux.HandleFunc("POST /games/custom", deprecated("/games", serveCreateCustomGame))
+	mux.HandleFunc("GET /game/{id}/record", deprecated("/games/{id}/record", serveGameRecord))
+	mux.HandleFunc("POST /games/records", deprecated("/games/records", serveImportRecords))
+	mux.HandleFunc("GET /position", deprecated("/position", serveAnalyzePosition))
+	mux.HandleFunc("POST /games/actions", deprecated("/games/actions", servePerformActionBulk))
+	mux.HandleFunc("GET /games/active", deprecated("/games/active", serveActiveGames))
+	mux.HandleFunc("GET /games/active/me", deprecated("/players/me/games", serveActiveGamesUser))
+
+	// deprecated, use /games/active/me with the Authorization header
+	mux.HandleFunc("GET /games/active/{userToken}", deprecated("/players/me/games", serveActiveGamesUser))
+	mux.HandleFunc("POST /game/{id}/resign", deprecated("/games/{id}/resign", serveResign))
+	mux.HandleFunc("POST /game/{id}/draw", deprecated("/games/{id}/draw", serveDrawOffer))
+	mux.HandleFunc("POST /game/{id}/draw/decline", deprecated("/games/{id}/draw", serveDrawDecline))
 
 }
@@ -19,24 +19,88 @@ func main() {
 		log.Println("Warning: Could not load .env file, using system environment variables")
 	}
 
+	loadDefaultRules()
+	loadBoardCatalog()
+	loadAdjudicationConfig()
+	loadTimeoutConfig()
+	loadLegacyTokens()
+	loadTeamConfig()
+	loadRateLimitConfig()
+	loadCorsConfig()
+	loadGrpcConfig()
+	loadAdminConfig()
+
+	// Fill the cache of active games
+	err = activeGames.Load()
+	if err != nil {
+		slog.Error("Error loading active game cache, falling back to database", "error", err)
+	}
+
 	// paths: /game
 	InitHttpHandler_Game_Handler()
 
 	// paths: /user
 	InitHttpHandler_Users()
 
+	// paths: /user/tokens, /user/token/rotate
+	InitHttpHandler_Tokens()
+
+	// paths: /user/me/version
+	InitHttpHandler_Versions()
+
+	// paths: /owner
+	InitHttpHandler_Owners()
+
+	// paths: /teams, /owner/teams, /config/teams
+	InitHttpHandler_Teams()
+
+	// paths: /players
+	InitHttpHandler_Profiles()
+
+	// paths: /stats
+	InitHttpHandler_Stats()
+
 	// paths: /match
 	// InitHttpHandler_Match_Making()
 
+	// paths: /config
+	InitHttpHandler_Config()
+
+	// paths: /events, /game/{id}/events
+	InitHttpHandler_Events()
+
+	// paths: /metrics/ratelimit
+	InitHttpHandler_RateLimit()
+
+	// paths: /api/v1
+	InitHttpHandler_API()
+
+	// paths: /admin
+	InitHttpHandler_Admin()
+
 	InitHttpHandler_Frontend_Handler()
 
+	// paths: all paths without handler
+	InitHttpHandler_Errors()
+
 	// Start periodic job to ensure games are running
 	go runPeriodicJob()
 
+	// gRPC API for bots, see arenapb/arena.proto
+	go serveGRPC()
+
 	// Start server
 	log.Println("Server is starting...")
 	log.Println("http://localhost:8081")
-	log.Println(http.ListenAndServe(":8081", nil))
+	handler := Chain(mux,
+		RequestID,
+		AccessLog,
+		Recover,
+		CORS,
+		rateLimiter.Middleware(mux),
+		Gzip,
+	)
+	log.Println(http.ListenAndServe(":8081", handler))
 }
 
 func runPeriodicJob() {
@@ -51,6 +115,14 @@ func runPeriodicJob() {
 			if err != nil {
 				slog.Error("Error ensuring games are running (regular)", "error", err)
 			}
+			err = adjudicator.adjudicateGames()
+			if err != nil {
+				slog.Error("Error adjudicating games", "error", err)
+			}
+			err = timeoutGames(time.Now())
+			if err != nil {
+				slog.Error("Error ending games on time", "error", err)
+			}
 		}
 	}
 }
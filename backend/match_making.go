@@ -20,23 +20,20 @@ var (
 )
 
 func serveLookingForMatch(w http.ResponseWriter, r *http.Request) {
-	token := r.PathValue("token")
-
-	player, err := DB_Get_Player_by_Token(token)
-	if err != nil {
-		http.Error(w, "Invalid token (error:"+err.Error()+")", http.StatusUnauthorized)
+	player := authenticate(w, r)
+	if player == nil {
 		return
 	}
 
 	gameCountStr := r.URL.Query().Get("gameCount")
 
 	if gameCountStr == "" {
-		http.Error(w, "Missing gameCount", http.StatusBadRequest)
+		writeError(w, ERR_BAD_REQUEST, "Missing gameCount")
 		return
 	}
 	gameCount, err := strconv.Atoi(gameCountStr)
 	if err != nil {
-		http.Error(w, "Invalid gameCount (should be an integer)", http.StatusBadRequest)
+		writeError(w, ERR_BAD_REQUEST, "Invalid gameCount (should be an integer)")
 		return
 	}
 
@@ -118,9 +115,6 @@ func serveLookingForMatch(w http.ResponseWriter, r *http.Request) {
 }
 
 func InitHttpHandler_Match_Making() {
-	http.HandleFunc("GET /match/queueup/{token}", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveLookingForMatch(w, r)
-	})
+	mux.HandleFunc("GET /match/queueup/{token}", serveLookingForMatch)
 
 }
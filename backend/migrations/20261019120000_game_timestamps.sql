@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Game ADD COLUMN CreatedAt INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Game ADD COLUMN EndedAt INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Game DROP COLUMN EndedAt;
+ALTER TABLE Game DROP COLUMN CreatedAt;
+-- +goose StatementEnd
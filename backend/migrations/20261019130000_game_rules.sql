@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- comma separated rule variants (see rules.go), empty for the standard rules
+ALTER TABLE Game ADD COLUMN Rules TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Game DROP COLUMN Rules;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- position string (see notation.go) the game started from, empty for the standard setup
+ALTER TABLE Game ADD COLUMN StartPosition TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Game DROP COLUMN StartPosition;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- why the game ended (see termination.go), empty while the game is running
+ALTER TABLE Game ADD COLUMN Termination TEXT NOT NULL DEFAULT '';
+-- player number (1 or 2) with a pending draw offer, 0 for none
+ALTER TABLE Game ADD COLUMN DrawOffer INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Game DROP COLUMN DrawOffer;
+ALTER TABLE Game DROP COLUMN Termination;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- API tokens of the players, only the SHA-256 hash of a token is stored.
+-- Plain text tokens left in Player.SecretToken are hashed into this table
+-- and cleared on server start (see DB_Hash_Legacy_Tokens).
+CREATE TABLE IF NOT EXISTS ApiToken (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    PlayerID INTEGER NOT NULL,
+    TokenHash TEXT NOT NULL UNIQUE,
+    -- first characters of the token, to tell tokens apart
+    Prefix TEXT NOT NULL,
+    CreatedAt INTEGER NOT NULL,
+    -- unix time of the revocation, 0 while the token is valid
+    RevokedAt INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (PlayerID) REFERENCES Player(ID)
+);
+CREATE INDEX IF NOT EXISTS ApiTokenPlayer ON ApiToken (PlayerID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS ApiTokenPlayer;
+DROP TABLE IF EXISTS ApiToken;
+-- +goose StatementEnd
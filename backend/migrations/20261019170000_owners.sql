@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+-- human accounts that manage bots, the password is stored as bcrypt hash
+CREATE TABLE IF NOT EXISTS Owner (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Username TEXT NOT NULL,
+    PasswordHash TEXT NOT NULL,
+    CreatedAt INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS OwnerUsername ON Owner (Username COLLATE NOCASE);
+
+-- login sessions of owners, only the hash of the session token is stored
+CREATE TABLE IF NOT EXISTS OwnerSession (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    OwnerID INTEGER NOT NULL,
+    TokenHash TEXT NOT NULL UNIQUE,
+    CreatedAt INTEGER NOT NULL,
+    ExpiresAt INTEGER NOT NULL,
+    FOREIGN KEY (OwnerID) REFERENCES Owner(ID)
+);
+
+-- owner of the bot, 0 for bots created by the anonymous sign up
+ALTER TABLE Player ADD COLUMN OwnerID INTEGER NOT NULL DEFAULT 0;
+-- unix time the bot was retired, 0 while it plays
+ALTER TABLE Player ADD COLUMN RetiredAt INTEGER NOT NULL DEFAULT 0;
+
+-- bot names are unique, existing duplicates get a number appended: the
+-- player id plus the first multiple of the largest id that gives a free name.
+-- The numbers of different players differ, so do the new names.
+CREATE TEMP TABLE PlayerRename AS
+WITH RECURSIVE
+Duplicate AS (
+    SELECT ID, Name FROM Player
+    WHERE EXISTS (SELECT 1 FROM Player p WHERE p.Name = Player.Name COLLATE NOCASE AND p.ID < Player.ID)
+),
+Candidate (ID, Name, Number) AS (
+    SELECT ID, Name, ID FROM Duplicate
+    UNION ALL
+    SELECT c.ID, c.Name, c.Number + (SELECT MAX(ID) FROM Player) FROM Candidate c
+    WHERE EXISTS (SELECT 1 FROM Player p WHERE p.Name COLLATE NOCASE = c.Name || '-' || c.Number)
+)
+SELECT ID, Name || '-' || MAX(Number) AS Name FROM Candidate GROUP BY ID;
+UPDATE Player SET Name = (SELECT r.Name FROM PlayerRename r WHERE r.ID = Player.ID)
+WHERE ID IN (SELECT ID FROM PlayerRename);
+DROP TABLE PlayerRename;
+CREATE UNIQUE INDEX IF NOT EXISTS PlayerName ON Player (Name COLLATE NOCASE);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS PlayerName;
+ALTER TABLE Player DROP COLUMN RetiredAt;
+ALTER TABLE Player DROP COLUMN OwnerID;
+DROP TABLE IF EXISTS OwnerSession;
+DROP INDEX IF EXISTS OwnerUsername;
+DROP TABLE IF EXISTS Owner;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- rating tracks of the versions of a bot, a new version starts with the
+-- rating of the version played before (SeedElo)
+CREATE TABLE IF NOT EXISTS PlayerVersion (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    PlayerID INTEGER NOT NULL,
+    Version TEXT NOT NULL,
+    Elo INTEGER NOT NULL,
+    SeedElo INTEGER NOT NULL,
+    CreatedAt INTEGER NOT NULL,
+    FOREIGN KEY (PlayerID) REFERENCES Player(ID),
+    CONSTRAINT UniquePlayerVersion UNIQUE (PlayerID, Version)
+);
+
+-- version new games of the player are played with, Player.Elo is the rating
+-- of this version
+ALTER TABLE Player ADD COLUMN CurrentVersionID INTEGER NOT NULL DEFAULT 0;
+-- versions that played the game, they are rated at the end
+ALTER TABLE Game ADD COLUMN Player1VersionID INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Game ADD COLUMN Player2VersionID INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE HistoryEntry ADD COLUMN VersionID INTEGER NOT NULL DEFAULT 0;
+
+-- existing players play an unnamed version with their current rating
+INSERT INTO PlayerVersion (PlayerID, Version, Elo, SeedElo, CreatedAt)
+SELECT ID, '', Elo, 1000, 0 FROM Player;
+UPDATE Player SET CurrentVersionID = (SELECT v.ID FROM PlayerVersion v WHERE v.PlayerID = Player.ID AND v.Version = '');
+UPDATE Game SET
+    Player1VersionID = (SELECT p.CurrentVersionID FROM Player p WHERE p.ID = Game.Player1ID),
+    Player2VersionID = (SELECT p.CurrentVersionID FROM Player p WHERE p.ID = Game.Player2ID);
+UPDATE HistoryEntry SET VersionID = (SELECT p.CurrentVersionID FROM Player p WHERE p.ID = HistoryEntry.PlayerID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE HistoryEntry DROP COLUMN VersionID;
+ALTER TABLE Game DROP COLUMN Player2VersionID;
+ALTER TABLE Game DROP COLUMN Player1VersionID;
+ALTER TABLE Player DROP COLUMN CurrentVersionID;
+DROP TABLE IF EXISTS PlayerVersion;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- teams of bots, managed by the owner that created the team. Bots join with
+-- the join code of the team.
+CREATE TABLE IF NOT EXISTS Team (
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    Name TEXT NOT NULL,
+    ManagerID INTEGER NOT NULL,
+    JoinCode TEXT NOT NULL,
+    CreatedAt INTEGER NOT NULL,
+    FOREIGN KEY (ManagerID) REFERENCES Owner(ID)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS TeamName ON Team (Name COLLATE NOCASE);
+
+-- team of the bot, 0 for none
+ALTER TABLE Player ADD COLUMN TeamID INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Player DROP COLUMN TeamID;
+DROP INDEX IF EXISTS TeamName;
+DROP TABLE IF EXISTS Team;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- unix time of the last move, 0 before the first move
+ALTER TABLE Game ADD COLUMN LastMoveAt INTEGER NOT NULL DEFAULT 0;
+-- the clock of running games starts with the migration
+UPDATE Game SET LastMoveAt = CAST(strftime('%s', 'now') AS INTEGER) WHERE Outcome = 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Game DROP COLUMN LastMoveAt;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func TestOwnersMigrationRenamesDuplicateNames(t *testing.T) {
+	openTestDB(t, testSeed{
+		before: "20261019170000_owners.sql",
+		sql: `INSERT INTO Player (ID, Name, SecretToken, Elo) VALUES
+			(1, 'bot', 'a', 1000), (2, 'bot', 'b', 1000), (3, 'bot-2', 'c', 1000), (4, 'Bot', 'd', 1000), (5, 'other', 'e', 1000)`,
+	})
+
+	db, err := sql.Open("sqlite3", os.Getenv("DB_PATH"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// bot-2 is taken, the second bot gets the next number for its id
+	want := map[int]string{1: "bot", 2: "bot-7", 3: "bot-2", 4: "Bot-4", 5: "other"}
+	for id, name := range want {
+		var got string
+		if err := db.QueryRow("SELECT Name FROM Player WHERE ID = ?", id).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != name {
+			t.Errorf("player %d is named %q, want %q", id, got, name)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Text notation for positions and moves.
+//
+// A position looks like "5x3 ppp/3/3/3/PPP 1": the board size (rows x cols),
+// the rows from the last row (player 2's side) down to row 0 separated by '/',
+// and the player to move. Within a row 'P' is a pawn of player 1, 'p' a pawn
+// of player 2 and numbers count empty cells. Rule variants are appended as
+// optional fourth field, e.g. "8x8 pppppppp/8/8/8/8/8/8/PPPPPPPP 1 double_step".
+//
+// Moves use algebraic coordinates: columns are letters starting at 'a', rows
+// are numbered starting at 1. A straight move is written "a2-a3", a capture
+// "b4xc5".
+
+const MAX_NOTATION_SIZE = 26 // columns are written as single letters
+
+func (g *GameState) Position() string {
+	if g.Rules.IsStandard() {
+		return g.placement()
+	}
+	return g.placement() + " " + g.Rules.String()
+}
+
+// placement is the position without the rules
+func (g *GameState) placement() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%dx%d ", g.Rows, g.Cols)
+
+	for row := g.Rows - 1; row >= 0; row-- {
+		empty := 0
+		for col := 0; col < g.Cols; col++ {
+			cell := g.Board[row][col]
+			if cell == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			if cell == 1 {
+				sb.WriteByte('P')
+			} else {
+				sb.WriteByte('p')
+			}
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if row > 0 {
+			sb.WriteByte('/')
+		}
+	}
+
+	fmt.Fprintf(&sb, " %d", g.NextPlayer())
+	return sb.String()
+}
+
+// ParsePosition creates a game state (without history) from a position string.
+func ParsePosition(position string) (*GameState, error) {
+	fields := strings.Fields(position)
+	if len(fields) != 3 && len(fields) != 4 {
+		return nil, fmt.Errorf("position needs 3 fields (size, rows, player to move) and optional rules, got %d", len(fields))
+	}
+
+	var rows, cols int
+	_, err := fmt.Sscanf(fields[0], "%dx%d", &rows, &cols)
+	if err != nil {
+		return nil, fmt.Errorf("invalid board size %q", fields[0])
+	}
+	if rows < 2 || cols < 1 || rows > MAX_NOTATION_SIZE || cols > MAX_NOTATION_SIZE {
+		return nil, fmt.Errorf("board size %dx%d out of range", rows, cols)
+	}
+
+	ranks := strings.Split(fields[1], "/")
+	if len(ranks) != rows {
+		return nil, fmt.Errorf("expected %d rows, got %d", rows, len(ranks))
+	}
+
+	board := make([][]int, rows)
+	for i, rank := range ranks {
+		row := rows - 1 - i
+		board[row] = make([]int, 0, cols)
+		empty := 0
+		for j := 0; j < len(rank); j++ {
+			c := rank[j]
+			if c >= '0' && c <= '9' {
+				// checked before allocating, the count is not bounded otherwise
+				empty = empty*10 + int(c-'0')
+				if len(board[row])+empty > cols {
+					return nil, fmt.Errorf("row %d has more than %d cells", row+1, cols)
+				}
+				continue
+			}
+			board[row] = append(board[row], make([]int, empty)...)
+			empty = 0
+			if len(board[row]) >= cols {
+				return nil, fmt.Errorf("row %d has more than %d cells", row+1, cols)
+			}
+
+			switch c {
+			case 'P':
+				board[row] = append(board[row], 1)
+			case 'p':
+				board[row] = append(board[row], 2)
+			default:
+				return nil, fmt.Errorf("invalid character %q in row %d", c, row+1)
+			}
+		}
+		board[row] = append(board[row], make([]int, empty)...)
+
+		if len(board[row]) != cols {
+			return nil, fmt.Errorf("row %d has %d cells, expected %d", row+1, len(board[row]), cols)
+		}
+	}
+
+	var next int
+	switch fields[2] {
+	case "1":
+		next = 1
+	case "2":
+		next = 2
+	default:
+		return nil, fmt.Errorf("invalid player to move %q", fields[2])
+	}
+
+	rules := RuleVariant{}
+	if len(fields) == 4 {
+		rules, err = ParseRuleVariant(fields[3])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &GameState{
+		Rows:     rows,
+		Cols:     cols,
+		History:  make([]Turn, 0),
+		Board:    board,
+		Rules:    rules,
+		hash:     zobristHash(board, next),
+		startPly: next - 1,
+	}, nil
+}
+
+func squareName(row int, col int) string {
+	return fmt.Sprintf("%c%d", 'a'+col, row+1)
+}
+
+func parseSquare(square string) (int, int, error) {
+	if len(square) < 2 || square[0] < 'a' || square[0] > 'z' {
+		return 0, 0, fmt.Errorf("invalid square %q", square)
+	}
+	row, err := strconv.Atoi(square[1:])
+	if err != nil || row < 1 {
+		return 0, 0, fmt.Errorf("invalid square %q", square)
+	}
+	return row - 1, int(square[0] - 'a'), nil
+}
+
+// Notation returns the move in algebraic notation. Pawns only capture
+// diagonally, so a column change marks a capture.
+func (t Turn) Notation() string {
+	sep := "-"
+	if t.SourceCol != t.DestCol {
+		sep = "x"
+	}
+	return squareName(t.SourceRow, t.SourceCol) + sep + squareName(t.DestRow, t.DestCol)
+}
+
+// ParseMove converts a move in algebraic notation into a legal Turn of the
+// player to move.
+func (g *GameState) ParseMove(move string) (Turn, error) {
+	move = strings.TrimSpace(move)
+
+	// the source square ends after its row digits, column x is a letter too
+	idx := 1
+	for idx < len(move) && move[idx] >= '0' && move[idx] <= '9' {
+		idx++
+	}
+	if idx >= len(move) || (move[idx] != '-' && move[idx] != 'x') {
+		return Turn{}, fmt.Errorf("invalid move %q (expected e.g. a2-a3 or b4xc5)", move)
+	}
+
+	srow, scol, err := parseSquare(move[:idx])
+	if err != nil {
+		return Turn{}, err
+	}
+	drow, dcol, err := parseSquare(move[idx+1:])
+	if err != nil {
+		return Turn{}, err
+	}
+
+	it := g.Moves()
+	for candidate, ok := it.Next(); ok; candidate, ok = it.Next() {
+		if candidate.SourceRow == srow && candidate.SourceCol == scol && candidate.DestRow == drow && candidate.DestCol == dcol {
+			if (move[idx] == 'x') != (scol != dcol) {
+				return Turn{}, fmt.Errorf("move %q: capture marker does not match the move", move)
+			}
+			return candidate, nil
+		}
+	}
+	return Turn{}, fmt.Errorf("move %q is not legal in this position", move)
+}
+
+// PositionView is the text representation of a game state.
+type PositionView struct {
+	Start         string   `json:"start,omitempty"`
+	Position      string   `json:"position"`
+	History       []string `json:"history"`
+	MoveOptions   []string `json:"moveOptions"`
+	CurrentPlayer int      `json:"currentPlayer"`
+	GameOver      bool     `json:"gameOver"`
+	Winner        int      `json:"winner"`
+}
+
+func (g *GameState) PositionView() PositionView {
+	history := make([]string, 0, len(g.History))
+	for _, turn := range g.History {
+		history = append(history, turn.Notation())
+	}
+
+	options := make([]string, 0)
+	it := g.Moves()
+	for move, ok := it.Next(); ok; move, ok = it.Next() {
+		options = append(options, move.Notation())
+	}
+
+	winner := g.GetWinner()
+	return PositionView{
+		Start:         g.start,
+		Position:      g.Position(),
+		History:       history,
+		MoveOptions:   options,
+		CurrentPlayer: g.NextPlayer(),
+		GameOver:      winner != 0,
+		Winner:        winner,
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestParsePositionRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range bitboardSizes {
+		state := randomPosition(rng, size[0], size[1], 2*size[0])
+		parsed, err := ParsePosition(state.Position())
+		if err != nil {
+			t.Fatalf("%s: %v", state.Position(), err)
+		}
+		if parsed.Position() != state.Position() || parsed.Hash() != state.Hash() {
+			t.Errorf("%s parsed as %s", state.Position(), parsed.Position())
+		}
+	}
+}
+
+func TestParsePositionInvalid(t *testing.T) {
+	for _, position := range []string{
+		"2x2 99999999999/PP 1",
+		"2x2 99999999999999999999999/PP 1",
+		"2x2 3/PP 1",
+		"2x2 1p1/PP 1",
+		"2x2 ppp/PP 1",
+		"2x2 p/PP 1",
+		"2x2 pp/PP/2 1",
+		"2x2 pp/PP 3",
+		"2x2 px/PP 1",
+		"99x2 pp/PP 1",
+	} {
+		if _, err := ParsePosition(position); err == nil {
+			t.Errorf("%q was accepted", position)
+		}
+	}
+}
+
+func TestParseMoveWideBoard(t *testing.T) {
+	state := NewGameState(5, 26)
+	for _, move := range []string{"x1-x2", "a1-a2", "z1-z2"} {
+		turn, err := state.ParseMove(move)
+		if err != nil {
+			t.Errorf("%s: %v", move, err)
+			continue
+		}
+		if turn.Notation() != move {
+			t.Errorf("%s parsed as %s", move, turn.Notation())
+		}
+	}
+
+	// captures into and out of column x
+	state = NewGameState(5, 24)
+	play := func(moves ...string) {
+		for _, move := range moves {
+			turn, err := state.ParseMove(move)
+			if err != nil {
+				t.Fatalf("%s: %v", move, err)
+			}
+			state.applyAction(turn)
+		}
+	}
+	play("w1-w2", "x5-x4", "w2-w3")
+	if _, err := state.ParseMove("x4xw3"); err != nil {
+		t.Error(err)
+	}
+	play("a5-a4")
+	if _, err := state.ParseMove("w3xx4"); err != nil {
+		t.Error(err)
+	}
+
+	for _, move := range []string{"", "x", "x1", "x1x", "x1-", "xx1-x2", "x12x2"} {
+		if _, err := state.ParseMove(move); err == nil {
+			t.Errorf("%q was accepted", move)
+		}
+	}
+}
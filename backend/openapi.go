@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// The OpenAPI document is generated from apiRoutes, the schemas of the
+// bodies from the Go types by reflection, such that it can not get out of
+// date with the handlers.
+
+const OPENAPI_VERSION = "3.0.3"
+
+// types with a MarshalJSON are described by the type they marshal to
+var schemaTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(GameState{}): reflect.TypeOf(gameStateJSON{}),
+}
+
+type schemaGenerator struct {
+	components map[string]any
+}
+
+func (g *schemaGenerator) ref(t reflect.Type) map[string]any {
+	name := ""
+	if t.Name() != "" {
+		name = componentName(t)
+	}
+	if replacement, ok := schemaTypes[t]; ok {
+		t = replacement
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.ref(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			// siblings of $ref are ignored in OpenAPI 3.0
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Struct:
+		if name == "" {
+			return g.object(t)
+		}
+		if _, done := g.components[name]; !done {
+			g.components[name] = nil // guards recursive types
+			g.components[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		schema := map[string]any{"type": "array", "items": g.ref(t.Elem())}
+		if t.Kind() == reflect.Array {
+			schema["minItems"] = t.Len()
+			schema["maxItems"] = t.Len()
+		}
+		return schema
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.ref(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	// interfaces hold any value
+	return map[string]any{}
+}
+
+// object describes the fields of a struct like encoding/json sees them.
+func (g *schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	g.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		// embedded structs without name add their fields
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if replacement, ok := schemaTypes[field.Type]; ok {
+				g.fields(replacement, properties, required)
+			} else {
+				g.fields(field.Type, properties, required)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.ref(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// openAPIDocument builds the document of the routes.
+func openAPIDocument(routes []apiRoute) map[string]any {
+	g := &schemaGenerator{components: map[string]any{}}
+	errorResponse := map[string]any{"$ref": "#/components/responses/Error"}
+
+	paths := map[string]map[string]any{}
+	for _, route := range routes {
+		operation := map[string]any{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"tags":        []string{route.Tag},
+		}
+
+		parameters := []any{}
+		for _, match := range pathWildcard.FindAllStringSubmatch(route.Path, -1) {
+			schemaType := "integer"
+			if match[1] == "name" {
+				schemaType = "string"
+			}
+			parameters = append(parameters, map[string]any{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]any{"type": schemaType},
+			})
+		}
+		for _, param := range route.Query {
+			parameters = append(parameters, map[string]any{
+				"name": param.Name, "in": "query", "required": param.Required,
+				"description": param.Description,
+				"schema":      map[string]any{"type": param.Type},
+			})
+		}
+		for _, param := range route.Headers {
+			parameters = append(parameters, map[string]any{
+				"name": param.Name, "in": "header", "required": param.Required,
+				"description": param.Description,
+				"schema":      map[string]any{"type": param.Type},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		switch route.Auth {
+		case AUTH_PLAYER:
+			operation["security"] = []any{map[string]any{"playerToken": []string{}}}
+		case AUTH_OWNER:
+			operation["security"] = []any{
+				map[string]any{"ownerCookie": []string{}},
+				map[string]any{"ownerSession": []string{}},
+			}
+		}
+
+		if route.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  g.content(route.Request),
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		switch {
+		case route.EventStream:
+			success["content"] = map[string]any{"text/event-stream": map[string]any{"schema": map[string]any{"type": "string"}}}
+		case route.Response != nil:
+			success["content"] = g.content(route.Response)
+		}
+		responses := map[string]any{
+			strconv.Itoa(status): success,
+			"default":            errorResponse,
+		}
+		if route.Created {
+			created := map[string]any{"description": http.StatusText(http.StatusCreated)}
+			if content, ok := success["content"]; ok {
+				created["content"] = content
+			}
+			responses[strconv.Itoa(http.StatusCreated)] = created
+		}
+		operation["responses"] = responses
+
+		path := API_PREFIX + route.Path
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	errorSchema := g.ref(reflect.TypeOf(APIError{}))
+	codes := []string{}
+	for code := range errorStatus {
+		codes = append(codes, string(code))
+	}
+	slices.Sort(codes)
+	g.components["APIError"].(map[string]any)["properties"].(map[string]any)["code"] = map[string]any{
+		"type": "string", "enum": codes,
+	}
+
+	return map[string]any{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]any{
+			"title":       "RLarena API",
+			"version":     "1",
+			"description": "Bots play games against each other in the arena. The unversioned routes are deprecated aliases of these routes.",
+		},
+		"servers": []any{map[string]any{"url": "/"}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "Error, see the code for the reason",
+					"content":     map[string]any{"application/json": map[string]any{"schema": errorSchema}},
+				},
+			},
+			"securitySchemes": map[string]any{
+				"playerToken":  map[string]any{"type": "http", "scheme": "bearer", "description": "token of a bot"},
+				"ownerSession": map[string]any{"type": "http", "scheme": "bearer", "description": "session of an owner"},
+				"ownerCookie":  map[string]any{"type": "apiKey", "in": "cookie", "name": OWNER_SESSION_COOKIE},
+			},
+		},
+	}
+}
+
+// content describes a body, strings stand for plain text.
+func (g *schemaGenerator) content(value any) map[string]any {
+	if _, ok := value.(string); ok {
+		return map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}
+	}
+	return map[string]any{"application/json": map[string]any{"schema": g.ref(reflect.TypeOf(value))}}
+}
+
+// operationID is the method and the literal parts of the path in camel
+// case, e.g. getGamesPosition for GET /games/{id}/position.
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(route.Path, "/") {
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, "{") {
+			part = "by-" + strings.Trim(part, "{}")
+		}
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '.' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	return json.MarshalIndent(openAPIDocument(apiRoutes), "", "  ")
+})
+
+func serveOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	document, err := openAPIJSON()
+	if err != nil {
+		slog.Error("Error generating the OpenAPI document", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(document)
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Owners are the humans behind the bots. They log in with username and
+// password and manage their bots: create and retire them, handle their tokens
+// and see their results. Owner sessions are separate from the bot tokens, a
+// bot token never grants access to the owner endpoints and vice versa.
+const (
+	OWNER_SESSION_COOKIE   = "owner_session"
+	OWNER_SESSION_DURATION = 7 * 24 * time.Hour
+	OWNER_PASSWORD_MIN     = 8
+	OWNER_PASSWORD_MAX     = 72 // bcrypt ignores longer passwords
+)
+
+var ownerUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// compared against for unknown usernames, such that the response time does
+// not tell whether a username exists
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+type Owner struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type OwnedBot struct {
+	ID        int          `json:"id"`
+	Name      string       `json:"name"`
+	Elo       int          `json:"elo"`
+	Retired   bool         `json:"retired"`
+	RetiredAt int64        `json:"retired_at"`
+	Results   ResultCounts `json:"results"`
+}
+
+// OwnerOverview is the owner with all bots, Results sums up the results of
+// all bots.
+type OwnerOverview struct {
+	Owner
+	ActiveBots int          `json:"active_bots"`
+	Bots       []OwnedBot   `json:"bots"`
+	Results    ResultCounts `json:"results"`
+}
+
+// NewBot is a bot that was just created with its first token.
+type NewBot struct {
+	ID    int    `json:"id"`
+	Token string `json:"token"`
+}
+
+type OwnerSession struct {
+	Session   string `json:"session"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type ownerCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func readCredentials(w http.ResponseWriter, r *http.Request) (ownerCredentials, bool) {
+	var credentials ownerCredentials
+	err := json.NewDecoder(r.Body).Decode(&credentials)
+	if err != nil {
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input")
+		return credentials, false
+	}
+	return credentials, true
+}
+
+// sessionToken returns the session token of the cookie or, for scripts, of
+// the Authorization header.
+func sessionToken(r *http.Request) string {
+	if cookie, err := r.Cookie(OWNER_SESSION_COOKIE); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	return requestToken(r)
+}
+
+// authenticateOwner returns the owner of the session, or writes the error
+// response and returns nil.
+func authenticateOwner(w http.ResponseWriter, r *http.Request) *Owner {
+	token := sessionToken(r)
+	if token == "" {
+		writeError(w, ERR_UNAUTHORIZED, "Login required")
+		return nil
+	}
+
+	owner, err := DB_Get_Owner_by_Session(token)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_UNAUTHORIZED, "Session expired, please log in again")
+		return nil
+	}
+	if err != nil {
+		slog.Error("Error reading owner session", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return nil
+	}
+	return owner
+}
+
+// ownedBot reads the bot of the request path and checks that it belongs to
+// the owner, or writes the error response and returns nil.
+func ownedBot(w http.ResponseWriter, r *http.Request, owner *Owner) *Player {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return nil
+	}
+
+	player, err := DB_Get_Player(id)
+	if err != nil || player.OwnerID != owner.ID {
+		// bots of other owners are not revealed
+		writeError(w, ERR_NOT_FOUND, "Bot not found")
+		return nil
+	}
+	return player
+}
+
+func serveOwnerRegister(w http.ResponseWriter, r *http.Request) {
+	credentials, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+	if !ownerUsernamePattern.MatchString(credentials.Username) {
+		writeError(w, ERR_BAD_REQUEST, "Username has to be 3 to 32 letters, digits, '_', '.' or '-'")
+		return
+	}
+	if len(credentials.Password) < OWNER_PASSWORD_MIN || len(credentials.Password) > OWNER_PASSWORD_MAX {
+		writeError(w, ERR_BAD_REQUEST, "Password has to be 8 to 72 bytes long")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
+	if err != nil {
+		slog.Error("Error hashing password", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	owner, err := DB_Create_Owner(credentials.Username, string(hash))
+	if errors.Is(err, ErrNameTaken) {
+		writeError(w, ERR_NAME_TAKEN, "Username is already taken")
+		return
+	}
+	if err != nil {
+		slog.Error("Error creating owner", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(owner)
+}
+
+func serveOwnerLogin(w http.ResponseWriter, r *http.Request) {
+	credentials, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	owner, hash, err := DB_Get_Owner_by_Username(credentials.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Error reading owner", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	if owner == nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(credentials.Password))
+		writeError(w, ERR_UNAUTHORIZED, "Invalid username or password")
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(credentials.Password)) != nil {
+		writeError(w, ERR_UNAUTHORIZED, "Invalid username or password")
+		return
+	}
+
+	token, expiresAt, err := DB_Create_Owner_Session(owner.ID, OWNER_SESSION_DURATION)
+	if err != nil {
+		slog.Error("Error creating owner session", "owner", owner.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OWNER_SESSION_COOKIE,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Unix(expiresAt, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OwnerSession{Session: token, ExpiresAt: expiresAt})
+}
+
+func serveOwnerLogout(w http.ResponseWriter, r *http.Request) {
+	token := sessionToken(r)
+	if token != "" {
+		err := DB_Delete_Owner_Session(token)
+		if err != nil {
+			slog.Error("Error deleting owner session", "error", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: OWNER_SESSION_COOKIE, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func serveOwnerOverview(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+
+	bots, err := DB_Get_Owner_Bots(owner.ID)
+	if err != nil {
+		slog.Error("Error reading bots of owner", "owner", owner.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	overview := OwnerOverview{Owner: *owner, Bots: bots}
+	for _, bot := range bots {
+		if !bot.Retired {
+			overview.ActiveBots++
+		}
+		overview.Results.Wins += bot.Results.Wins
+		overview.Results.Draws += bot.Results.Draws
+		overview.Results.Losses += bot.Results.Losses
+	}
+	overview.Results.updateScore()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overview)
+}
+
+func serveOwnerCreateBot(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input")
+		return
+	}
+
+	id, token, ok := createPlayer(w, request.Name, owner.ID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NewBot{ID: id, Token: token})
+}
+
+// serveOwnerRetireBot retires the bot: its tokens are revoked, it gets no new
+// games and it resigns its running games. Its name stays reserved.
+func serveOwnerRetireBot(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+	player := ownedBot(w, r, owner)
+	if player == nil {
+		return
+	}
+
+	err := DB_Retire_Player(owner.ID, player.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_BOT_RETIRED, "Bot is already retired")
+		return
+	}
+	if err != nil {
+		slog.Error("Error retiring bot", "owner", owner.ID, "player", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	var games []Game
+	if activeGames.Loaded() {
+		games = activeGames.GetByPlayer(player.ID)
+	} else {
+		games, err = DB_Get_Active_Games_By_Player(player)
+		if err != nil {
+			slog.Error("Error reading games of retired bot", "player", player.ID, "error", err)
+		}
+	}
+	for i := range games {
+		game := &games[i]
+		err = endGame(game, 3-game.PlayerNumber(player.ID), TERMINATION_RESIGNATION)
+		if err != nil {
+			slog.Warn("Error resigning game of retired bot", "game", game.ID, "error", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func serveOwnerBotTokens(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+	player := ownedBot(w, r, owner)
+	if player == nil {
+		return
+	}
+
+	tokens, err := DB_Get_Tokens(player.ID)
+	if err != nil {
+		slog.Error("Error reading tokens", "playerID", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// serveOwnerCreateBotToken issues a new token, e.g. when the bot lost its
+// token.
+func serveOwnerCreateBotToken(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+	player := ownedBot(w, r, owner)
+	if player == nil {
+		return
+	}
+	if player.Retired {
+		writeError(w, ERR_BOT_RETIRED, "Bot is retired")
+		return
+	}
+
+	token, id, err := DB_Create_Token(player.ID)
+	if err != nil {
+		slog.Error("Error creating token", "playerID", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	writeNewToken(w, id, token)
+}
+
+func serveOwnerRevokeBotToken(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+	player := ownedBot(w, r, owner)
+	if player == nil {
+		return
+	}
+
+	tokenID, err := strconv.Atoi(r.PathValue("tokenID"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid token ID")
+		return
+	}
+
+	err = DB_Revoke_Token(player.ID, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_NOT_FOUND, "Token not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Error revoking token", "playerID", player.ID, "tokenID", tokenID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func InitHttpHandler_Owners() {
+	mux.HandleFunc("POST /owner/register", deprecated("/owners", serveOwnerRegister))
+	mux.HandleFunc("POST /owner/login", deprecated("/owner/session", serveOwnerLogin))
+	mux.HandleFunc("POST /owner/logout", deprecated("/owner/session", serveOwnerLogout))
+	mux.HandleFunc("GET /owner/me", deprecated("/owner", serveOwnerOverview))
+	mux.HandleFunc("POST /owner/bots", deprecated("/owner/bots", serveOwnerCreateBot))
+	mux.HandleFunc("DELETE /owner/bots/{id}", deprecated("/owner/bots/{id}", serveOwnerRetireBot))
+	mux.HandleFunc("GET /owner/bots/{id}/tokens", deprecated("/owner/bots/{id}/tokens", serveOwnerBotTokens))
+	mux.HandleFunc("POST /owner/bots/{id}/tokens", deprecated("/owner/bots/{id}/tokens", serveOwnerCreateBotToken))
+	mux.HandleFunc("DELETE /owner/bots/{id}/tokens/{tokenID}", deprecated("/owner/bots/{id}/tokens/{tokenID}", serveOwnerRevokeBotToken))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const PROFILE_RECENT_GAMES = 10
+
+// ResultCounts are the results of finished (rated) games from the view of
+// one player. Score is the share of points, a draw counts half.
+type ResultCounts struct {
+	Games  int     `json:"games"`
+	Wins   int     `json:"wins"`
+	Draws  int     `json:"draws"`
+	Losses int     `json:"losses"`
+	Score  float64 `json:"score"`
+}
+
+func (c *ResultCounts) updateScore() {
+	c.Games = c.Wins + c.Draws + c.Losses
+	c.Score = 0
+	if c.Games > 0 {
+		c.Score = (float64(c.Wins) + 0.5*float64(c.Draws)) / float64(c.Games)
+	}
+}
+
+type BoardStats struct {
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+	ResultCounts
+}
+
+// ColorStats splits the results by the player number, player 1 moves first.
+type ColorStats struct {
+	Player int `json:"player"`
+	ResultCounts
+}
+
+type OpponentStats struct {
+	OpponentID   int    `json:"opponent_id"`
+	OpponentName string `json:"opponent_name"`
+	ResultCounts
+}
+
+type RatingPoint struct {
+	GameID  int   `json:"game_id"`
+	Elo     int   `json:"elo"`
+	EndedAt int64 `json:"ended_at"`
+}
+
+type RecentGame struct {
+	GameID       int    `json:"game_id"`
+	OpponentID   int    `json:"opponent_id"`
+	OpponentName string `json:"opponent_name"`
+	Player       int    `json:"player"` // 1 or 2
+	Result       string `json:"result"` // win, draw, loss or running
+	Termination  string `json:"termination"`
+	Version      string `json:"version"` // version of the player that played the game
+	Rows         int    `json:"rows"`
+	Cols         int    `json:"cols"`
+	Plies        int    `json:"plies"`
+	EndedAt      int64  `json:"ended_at"`
+}
+
+// PlayerProfile is the public view of a player. It never contains the token.
+type PlayerProfile struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	CurrentElo int    `json:"current_elo"`
+
+	Results           ResultCounts    `json:"results"`
+	Rating            []RatingPoint   `json:"rating"` // after every rated game
+	ByBoard           []BoardStats    `json:"by_board"`
+	ByColor           []ColorStats    `json:"by_color"`
+	Opponents         []OpponentStats `json:"opponents"`
+	AverageGameLength float64         `json:"average_game_length"` // plies of finished games
+	Versions          []PlayerVersion `json:"versions"`
+	RecentGames       []RecentGame    `json:"recent_games"`
+}
+
+func writeProfile(w http.ResponseWriter, playerID int) {
+	profile, err := DB_Get_Player_Profile(playerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_NOT_FOUND, "Player not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Error reading player profile", "playerID", playerID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func serveProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
+	writeProfile(w, id)
+}
+
+func serveProfileByName(w http.ResponseWriter, r *http.Request) {
+	ids, err := DB_Find_Player_IDs_by_Name(r.PathValue("name"))
+	if err != nil {
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	switch len(ids) {
+	case 0:
+		writeError(w, ERR_NOT_FOUND, "Player not found")
+	case 1:
+		writeProfile(w, ids[0])
+	default:
+		writeError(w, ERR_CONFLICT, "Several players have this name, use the player id")
+	}
+}
+
+func InitHttpHandler_Profiles() {
+	mux.HandleFunc("GET /players/{id}/profile", deprecated("/players/{id}/profile", serveProfile))
+	mux.HandleFunc("GET /players/by-name/{name}/profile", deprecated("/players/by-name/{name}/profile", serveProfileByName))
+}
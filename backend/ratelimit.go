@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Requests are rate limited with token buckets. Every endpoint belongs to a
+// group with its own limit. Requests without token are limited per IP
+// address. Requests with a player token are limited per token and
+// additionally per IP address with a limit RATE_LIMIT_IP_FACTOR times as
+// high, such that several bots behind one address keep working. Only tokens
+// that exist count as tokens, made up ones are limited like requests without
+// token.
+const (
+	RATE_LIMIT_PLAY  = "play"  // moves and the polling of bots
+	RATE_LIMIT_READ  = "read"  // everything else that only reads
+	RATE_LIMIT_WRITE = "write" // everything else that changes data
+	RATE_LIMIT_AUTH  = "auth"  // sign up and login, guessing is expensive
+
+	RATE_LIMIT_IP_FACTOR = 4
+	RATE_LIMIT_IDLE      = 10 * time.Minute // buckets unused for longer are removed
+	RATE_LIMIT_TOKEN_TTL = time.Minute      // valid tokens are checked again after this time
+)
+
+type RateLimit struct {
+	Rate  float64 `json:"rate"`  // requests per second, 0 disables the limit
+	Burst float64 `json:"burst"` // requests at once
+}
+
+// groups of the endpoints that are not grouped by their method
+var rateLimitGroups = map[string]string{
+	"POST /game/{id}/action":        RATE_LIMIT_PLAY,
+	"POST /game/{id}/move":          RATE_LIMIT_PLAY,
+	"POST /games/actions":           RATE_LIMIT_PLAY,
+	"POST /game/{id}/resign":        RATE_LIMIT_PLAY,
+	"POST /game/{id}/draw":          RATE_LIMIT_PLAY,
+	"POST /game/{id}/draw/decline":  RATE_LIMIT_PLAY,
+	"GET /game/{id}/state":          RATE_LIMIT_PLAY,
+	"GET /games/active/me":          RATE_LIMIT_PLAY,
+	"GET /games/active/{userToken}": RATE_LIMIT_PLAY,
+	"GET /user/signup":              RATE_LIMIT_AUTH,
+	"POST /owner/register":          RATE_LIMIT_AUTH,
+	"POST /owner/login":             RATE_LIMIT_AUTH,
+
+	"POST " + API_PREFIX + "/games/{id}/actions": RATE_LIMIT_PLAY,
+	"POST " + API_PREFIX + "/games/{id}/moves":   RATE_LIMIT_PLAY,
+	"POST " + API_PREFIX + "/games/actions":      RATE_LIMIT_PLAY,
+	"POST " + API_PREFIX + "/games/{id}/resign":  RATE_LIMIT_PLAY,
+	"POST " + API_PREFIX + "/games/{id}/draw":    RATE_LIMIT_PLAY,
+	"DELETE " + API_PREFIX + "/games/{id}/draw":  RATE_LIMIT_PLAY,
+	"GET " + API_PREFIX + "/games/{id}":          RATE_LIMIT_PLAY,
+	"GET " + API_PREFIX + "/players/me/games":    RATE_LIMIT_PLAY,
+	"POST " + API_PREFIX + "/players":            RATE_LIMIT_AUTH,
+	"POST " + API_PREFIX + "/owners":             RATE_LIMIT_AUTH,
+	"POST " + API_PREFIX + "/owner/session":      RATE_LIMIT_AUTH,
+}
+
+var defaultRateLimits = map[string]RateLimit{
+	RATE_LIMIT_PLAY:  {Rate: 20, Burst: 40},
+	RATE_LIMIT_READ:  {Rate: 10, Burst: 30},
+	RATE_LIMIT_WRITE: {Rate: 5, Burst: 10},
+	RATE_LIMIT_AUTH:  {Rate: 0.1, Burst: 5},
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitStats counts the requests of a group, throttled requests by the
+// kind of key (token or ip) that ran out.
+type RateLimitStats struct {
+	Limit     RateLimit        `json:"limit"`
+	Allowed   int64            `json:"allowed"`
+	Throttled map[string]int64 `json:"throttled"`
+}
+
+type RateLimiter struct {
+	mutex      sync.Mutex
+	limits     map[string]RateLimit
+	trustProxy bool
+	buckets    map[string]*tokenBucket
+	lastSweep  time.Time
+	stats      map[string]*RateLimitStats
+	tokens     map[string]time.Time   // token hash -> time it was found valid
+	validToken func(hash string) bool // looks the token up, without the mutex held
+}
+
+var rateLimiter = NewRateLimiter(defaultRateLimits)
+
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		limits:    make(map[string]RateLimit),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+		stats:     make(map[string]*RateLimitStats),
+		tokens:    make(map[string]time.Time),
+		validToken: func(hash string) bool {
+			active, err := DB_Token_Active(hash)
+			if err != nil {
+				slog.Error("Error checking token for the rate limit", "error", err)
+			}
+			return active
+		},
+	}
+	for group, limit := range limits {
+		l.setLimit(group, limit)
+	}
+	return l
+}
+
+func (l *RateLimiter) setLimit(group string, limit RateLimit) {
+	l.limits[group] = limit
+	l.stats[group] = &RateLimitStats{Limit: limit, Throttled: make(map[string]int64)}
+}
+
+// ParseRateLimits parses limits like "play=20:40,auth=0.1:5" (requests per
+// second and burst per group).
+func ParseRateLimits(value string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing '=' in %q", entry)
+		}
+		if _, known := defaultRateLimits[group]; !known {
+			return nil, fmt.Errorf("unknown group %q", group)
+		}
+		rateStr, burstStr, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("missing ':' in %q", entry)
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate in %q", entry)
+		}
+		burst, err := strconv.ParseFloat(burstStr, 64)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid burst in %q", entry)
+		}
+		limits[group] = RateLimit{Rate: rate, Burst: burst}
+	}
+	return limits, nil
+}
+
+// loadRateLimitConfig reads RATE_LIMITS, groups that are not listed keep
+// their default, and RATE_LIMIT_TRUST_PROXY.
+func loadRateLimitConfig() {
+	limits, err := ParseRateLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		slog.Error("Invalid RATE_LIMITS, using the defaults", "error", err)
+		limits = nil
+	}
+	for group, limit := range limits {
+		rateLimiter.setLimit(group, limit)
+	}
+
+	if value := os.Getenv("RATE_LIMIT_TRUST_PROXY"); value != "" {
+		trust, err := strconv.ParseBool(value)
+		if err != nil {
+			slog.Error("Invalid RATE_LIMIT_TRUST_PROXY, not trusting X-Forwarded-For", "value", value)
+			return
+		}
+		rateLimiter.trustProxy = trust
+	}
+	slog.Info("Rate limits loaded", "limits", rateLimiter.limits, "trustProxy", rateLimiter.trustProxy)
+}
+
+// group returns the rate limit group of the request.
+func (l *RateLimiter) group(r *http.Request, mux *http.ServeMux) string {
+	_, pattern := mux.Handler(r)
+	if group, ok := rateLimitGroups[pattern]; ok {
+		return group
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return RATE_LIMIT_READ
+	}
+	return RATE_LIMIT_WRITE
+}
+
+// clientIP is the address of the client, behind a trusted reverse proxy the
+// first address of X-Forwarded-For.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// take removes a token from the bucket of the key. It returns the time until
+// the next token is available if the bucket is empty.
+func (l *RateLimiter) take(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	b := l.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: limit.Burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep removes the buckets unused for RATE_LIMIT_IDLE and the expired
+// valid tokens, the mutex has to be held.
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > RATE_LIMIT_IDLE {
+			delete(l.buckets, key)
+		}
+	}
+	for hash, checked := range l.tokens {
+		if now.Sub(checked) > RATE_LIMIT_TOKEN_TTL {
+			delete(l.tokens, hash)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Allow decides on a request of the group. The token may be empty. A token
+// that is not known to be valid is limited like a request without token, it
+// is looked up once the request passed that limit and counts as token from
+// the next request on.
+func (l *RateLimiter) Allow(group string, token string, ip string) (bool, time.Duration) {
+	if token == "" {
+		return l.allow(group, "", ip)
+	}
+
+	// the hash keeps the tokens out of memory dumps
+	hash := hashToken(token)
+	l.mutex.Lock()
+	checked, known := l.tokens[hash]
+	known = known && time.Since(checked) <= RATE_LIMIT_TOKEN_TTL
+	unlimited := l.limits[group].Rate == 0
+	l.mutex.Unlock()
+	if known || unlimited {
+		return l.allow(group, hash, ip)
+	}
+
+	ok, wait := l.allow(group, "", ip)
+	if ok && l.validToken(hash) {
+		l.mutex.Lock()
+		l.tokens[hash] = time.Now()
+		l.mutex.Unlock()
+	}
+	return ok, wait
+}
+
+// allow charges the buckets of the request, tokenHash is empty for requests
+// without a valid token.
+func (l *RateLimiter) allow(group string, tokenHash string, ip string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	limit := l.limits[group]
+	stats := l.stats[group]
+	if limit.Rate == 0 {
+		stats.Allowed++
+		return true, 0
+	}
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > RATE_LIMIT_IDLE {
+		l.sweep(now)
+	}
+
+	if tokenHash == "" {
+		if ok, wait := l.take(group+"|anonymous|"+ip, limit, now); !ok {
+			stats.Throttled["ip"]++
+			return false, wait
+		}
+		stats.Allowed++
+		return true, 0
+	}
+
+	ipLimit := RateLimit{Rate: limit.Rate * RATE_LIMIT_IP_FACTOR, Burst: limit.Burst * RATE_LIMIT_IP_FACTOR}
+	if ok, wait := l.take(group+"|ip|"+ip, ipLimit, now); !ok {
+		stats.Throttled["ip"]++
+		return false, wait
+	}
+	if ok, wait := l.take(group+"|token|"+tokenHash, limit, now); !ok {
+		stats.Throttled["token"]++
+		return false, wait
+	}
+	stats.Allowed++
+	return true, 0
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests
+// before they reach the handlers, the groups are found by the patterns of
+// the mux.
+func (l *RateLimiter) Middleware(mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return l.handler(mux, next)
+	}
+}
+
+func (l *RateLimiter) handler(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := l.group(r, mux)
+		ok, wait := l.Allow(group, requestToken(r), l.clientIP(r))
+		if !ok {
+			seconds := max(int(math.Ceil(wait.Seconds())), 1)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeErrorDetails(w, ERR_RATE_LIMITED, fmt.Sprintf("Too many requests, retry after %ds", seconds),
+				map[string]any{"group": group, "retry_after": seconds})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats returns a copy of the statistics of all groups.
+func (l *RateLimiter) Stats() map[string]RateLimitStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stats := make(map[string]RateLimitStats, len(l.stats))
+	for group, s := range l.stats {
+		copied := *s
+		copied.Throttled = make(map[string]int64, len(s.Throttled))
+		for key, n := range s.Throttled {
+			copied.Throttled[key] = n
+		}
+		stats[group] = copied
+	}
+	return stats
+}
+
+func serveRateLimitMetrics(w http.ResponseWriter, _ *http.Request) {
+	rateLimiter.mutex.Lock()
+	buckets := len(rateLimiter.buckets)
+	rateLimiter.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"groups":  rateLimiter.Stats(),
+		"buckets": buckets,
+	})
+}
+
+func InitHttpHandler_RateLimit() {
+	mux.HandleFunc("GET /metrics/ratelimit", deprecated("/metrics/ratelimit", serveRateLimitMetrics))
+}
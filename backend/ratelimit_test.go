@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestRateLimiterUnknownTokens(t *testing.T) {
+	limiter := NewRateLimiter(map[string]RateLimit{RATE_LIMIT_PLAY: {Rate: 0.001, Burst: 2}})
+	lookups := 0
+	limiter.validToken = func(hash string) bool {
+		lookups++
+		return hash == hashToken("valid")
+	}
+
+	// made up tokens share the bucket of requests without token
+	for i, token := range []string{"", "made-up-1", "made-up-2"} {
+		ok, _ := limiter.Allow(RATE_LIMIT_PLAY, token, "10.0.0.1")
+		if ok != (i < 2) {
+			t.Errorf("request %d with token %q: allowed %v", i, token, ok)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("%d token lookups, want 1 (throttled requests are not looked up)", lookups)
+	}
+
+	// a valid token is charged like an anonymous request until it is known
+	if ok, _ := limiter.Allow(RATE_LIMIT_PLAY, "valid", "10.0.0.2"); !ok {
+		t.Fatal("first request with a valid token was throttled")
+	}
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow(RATE_LIMIT_PLAY, "valid", "10.0.0.2"); !ok {
+			t.Errorf("request %d with a known token was throttled", i)
+		}
+	}
+	if ok, _ := limiter.Allow(RATE_LIMIT_PLAY, "valid", "10.0.0.2"); ok {
+		t.Error("token bucket is not limited")
+	}
+	if lookups != 2 {
+		t.Errorf("%d token lookups, want 2", lookups)
+	}
+	if len(limiter.tokens) != 1 {
+		t.Errorf("%d remembered tokens, want 1", len(limiter.tokens))
+	}
+}
+
+func TestRateLimitConfigKeepsTrustOnInvalidValue(t *testing.T) {
+	discardLogs(t)
+	trust := rateLimiter.trustProxy
+	t.Cleanup(func() { rateLimiter.trustProxy = trust })
+
+	t.Setenv("RATE_LIMIT_TRUST_PROXY", "true")
+	loadRateLimitConfig()
+	t.Setenv("RATE_LIMIT_TRUST_PROXY", "yes please")
+	loadRateLimitConfig()
+	if !rateLimiter.trustProxy {
+		t.Error("invalid RATE_LIMIT_TRUST_PROXY overwrote the setting")
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Game records are a PGN-like text format: a block of headers followed by
+// the numbered moves in algebraic notation and the result, e.g.
+//
+//	[Event "RL Arena"]
+//	[GameID "12"]
+//	[Date "2024.12.06"]
+//	[Player1 "randomBot"]
+//	[Player2 "greedyBot"]
+//	[Player1Elo "1000"]
+//	[Player2Elo "1016"]
+//	[Board "3x3"]
+//	[Rules "double_step"]
+//	[Position "3x3 ppp/3/PPP 1"]
+//	[Result "0-1"]
+//	[Termination "breakthrough"]
+//
+//	1. a1-a2 b3xa2 2. c1-c2 a2-a1 0-1
+//
+// The Rules header is omitted for the standard rules, the Position header
+// for games starting from the standard setup. Several records can be
+// concatenated, separated by blank lines.
+
+// header order of exported records
+var RECORD_HEADERS = []string{"Event", "GameID", "Date", "Player1", "Player2", "Player1Elo", "Player2Elo", "Board", "Rules", "Position", "Result", "Termination"}
+
+type GameRecord struct {
+	Headers map[string]string `json:"headers"`
+	Moves   []string          `json:"moves"`
+	Result  string            `json:"result"`
+}
+
+func resultToken(outcome int) string {
+	switch outcome {
+	case 1:
+		return "1-0"
+	case 2:
+		return "0-1"
+	case -1:
+		return "1/2-1/2"
+	default:
+		return "*"
+	}
+}
+
+func outcomeFromToken(token string) (int, bool) {
+	switch token {
+	case "1-0":
+		return 1, true
+	case "0-1":
+		return 2, true
+	case "1/2-1/2":
+		return -1, true
+	case "*":
+		return 0, true
+	}
+	return 0, false
+}
+
+func NewGameRecord(game *Game, participants [2]GameParticipant) GameRecord {
+	moves := make([]string, 0, len(game.GameState.History))
+	for _, turn := range game.GameState.History {
+		moves = append(moves, turn.Notation())
+	}
+
+	result := resultToken(game.Outcome)
+	headers := map[string]string{
+		"Event":       "RL Arena",
+		"GameID":      strconv.Itoa(game.ID),
+		"Date":        time.Unix(game.CreatedAt, 0).UTC().Format("2006.01.02"),
+		"Player1":     participants[0].Name,
+		"Player2":     participants[1].Name,
+		"Player1Elo":  strconv.Itoa(participants[0].EloBefore),
+		"Player2Elo":  strconv.Itoa(participants[1].EloBefore),
+		"Board":       fmt.Sprintf("%dx%d", game.GameState.Rows, game.GameState.Cols),
+		"Result":      result,
+		"Termination": game.Termination,
+	}
+	if game.CreatedAt == 0 {
+		headers["Date"] = "????.??.??"
+	}
+	if !game.GameState.Rules.IsStandard() {
+		headers["Rules"] = game.GameState.Rules.String()
+	}
+	if start := game.GameState.StartPosition(); start != "" {
+		headers["Position"] = start
+	}
+	if headers["Termination"] == "" {
+		// games finished before termination reasons were stored
+		headers["Termination"] = terminationReason(game.GameState)
+	}
+	if headers["Termination"] == "" {
+		headers["Termination"] = "unterminated"
+	}
+
+	return GameRecord{
+		Headers: headers,
+		Moves:   moves,
+		Result:  result,
+	}
+}
+
+func (rec GameRecord) String() string {
+	var sb strings.Builder
+	for _, key := range RECORD_HEADERS {
+		if value, ok := rec.Headers[key]; ok {
+			fmt.Fprintf(&sb, "[%s %q]\n", key, value)
+		}
+	}
+	sb.WriteString("\n")
+
+	// body: "1. <player 1> <player 2> 2. ..." with at most 8 move pairs per line
+	for i, move := range rec.Moves {
+		if i%2 == 0 {
+			if i > 0 && i%16 == 0 {
+				sb.WriteString("\n")
+			} else if i > 0 {
+				sb.WriteString(" ")
+			}
+			fmt.Fprintf(&sb, "%d. ", i/2+1)
+		} else {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(move)
+	}
+	if len(rec.Moves) > 0 {
+		sb.WriteString(" ")
+	}
+	sb.WriteString(rec.Result)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func parseHeader(line string) (string, string, error) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+	key, value, found := strings.Cut(inner, " ")
+	if !found || !strings.HasSuffix(line, "]") {
+		return "", "", fmt.Errorf("invalid header %q", line)
+	}
+	value, err := strconv.Unquote(strings.TrimSpace(value))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid header value in %q", line)
+	}
+	return key, value, nil
+}
+
+// ParseRecords reads all records of the text. Every record is terminated by
+// its result token.
+func ParseRecords(text string) ([]GameRecord, error) {
+	records := make([]GameRecord, 0)
+	current := GameRecord{Headers: make(map[string]string), Moves: make([]string, 0)}
+	started := false
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		started = true
+
+		if strings.HasPrefix(line, "[") {
+			if len(current.Moves) > 0 {
+				return nil, fmt.Errorf("line %d: header after moves, result token missing", lineNo)
+			}
+			key, value, err := parseHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			current.Headers[key] = value
+			continue
+		}
+
+		for _, token := range strings.Fields(line) {
+			if _, ok := outcomeFromToken(token); ok {
+				current.Result = token
+				records = append(records, current)
+				current = GameRecord{Headers: make(map[string]string), Moves: make([]string, 0)}
+				started = false
+				continue
+			}
+			// skip move numbers ("1.")
+			if strings.HasSuffix(token, ".") {
+				if _, err := strconv.Atoi(strings.TrimSuffix(token, ".")); err == nil {
+					continue
+				}
+			}
+			current.Moves = append(current.Moves, token)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if started {
+		return nil, fmt.Errorf("last record is missing its result token")
+	}
+	return records, nil
+}
+
+// Replay validates the record by playing all moves through the rules engine.
+// It returns the final game state.
+func (rec GameRecord) Replay() (*GameState, error) {
+	var rows, cols int
+	_, err := fmt.Sscanf(rec.Headers["Board"], "%dx%d", &rows, &cols)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or missing Board header %q", rec.Headers["Board"])
+	}
+	if rows < 2 || cols < 1 || rows > MAX_NOTATION_SIZE || cols > MAX_NOTATION_SIZE {
+		return nil, fmt.Errorf("board size %dx%d out of range", rows, cols)
+	}
+
+	rules, err := ParseRuleVariant(rec.Headers["Rules"])
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := initialGameState(rows, cols, rules, rec.Headers["Position"])
+	if err != nil {
+		return nil, err
+	}
+	if state.StartPosition() != "" {
+		err = validateStartPosition(state)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start position: %w", err)
+		}
+	}
+
+	for i, move := range rec.Moves {
+		if state.IsEnd() {
+			return nil, fmt.Errorf("move %d (%s) played after the end of the game", i+1, move)
+		}
+		action, err := state.ParseMove(move)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %w", i+1, err)
+		}
+		if !state.applyAction(action) {
+			return nil, fmt.Errorf("move %d (%s) rejected by the rules", i+1, move)
+		}
+	}
+
+	outcome, _ := outcomeFromToken(rec.Result)
+	if header, ok := rec.Headers["Result"]; ok && header != rec.Result {
+		return nil, fmt.Errorf("result header %q does not match result token %q", header, rec.Result)
+	}
+	winner := state.GetWinner()
+	termination := rec.Headers["Termination"]
+	if winner == 0 && outcome != 0 && isOffBoardTermination(termination) {
+		// resigned, agreed or adjudicated before the end of the game
+		if termination == TERMINATION_AGREEMENT && outcome != -1 {
+			return nil, fmt.Errorf("result %s is not a draw but the termination is %q", rec.Result, termination)
+		}
+		return state, nil
+	}
+	if winner != outcome {
+		return nil, fmt.Errorf("result %s does not match the final position (expected %s)", rec.Result, resultToken(winner))
+	}
+
+	return state, nil
+}
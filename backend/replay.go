@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The replay page shows a game at a given ply (/replay/{id}?ply=N), rendered
+// on the server. Stepping through the moves follows plain links, such that
+// every position has a shareable URL.
+
+var replayTemplate *template.Template
+
+type ReplayCell struct {
+	Player int
+	Dark   bool
+	Class  string // last-from, last-to or captured
+}
+
+type ReplayMove struct {
+	Ply      int
+	Number   int // move number shown before the moves of player 1
+	Notation string
+	Player   int
+	Current  bool
+}
+
+type ReplayPage struct {
+	Game         *Game
+	Participants [2]GameParticipant
+	Date         string
+	Board        string
+	Rules        string
+	Start        string
+	Result       string
+	Termination  string
+
+	Ply      int
+	Plies    int
+	Prev     int
+	Next     int
+	LastMove string
+	Capture  bool
+	ColNames []string
+	Rows     []ReplayRow // top row first
+	Moves    []ReplayMove
+	Position string
+}
+
+type ReplayRow struct {
+	Name  int
+	Cells []ReplayCell
+}
+
+// statusText describes the result of the game for humans.
+func statusText(game *Game) string {
+	switch game.Outcome {
+	case 0:
+		return fmt.Sprintf("running, player %d to move", game.GameState.NextPlayer())
+	case -1:
+		return "draw"
+	default:
+		return fmt.Sprintf("player %d won", game.Outcome)
+	}
+}
+
+func NewReplayPage(game *Game, participants [2]GameParticipant, ply int) (*ReplayPage, error) {
+	history := game.GameState.History
+	state, err := initialGameState(game.GameState.Rows, game.GameState.Cols, game.GameState.Rules, game.GameState.StartPosition())
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ReplayPage{
+		Game:         game,
+		Participants: participants,
+		Date:         "unknown",
+		Board:        fmt.Sprintf("%dx%d", state.Rows, state.Cols),
+		Rules:        game.GameState.Rules.String(),
+		Start:        game.GameState.StartPosition(),
+		Result:       statusText(game),
+		Termination:  game.Termination,
+		Ply:          ply,
+		Plies:        len(history),
+		Prev:         max(ply-1, 0),
+		Next:         min(ply+1, len(history)),
+	}
+	if game.CreatedAt != 0 {
+		page.Date = time.Unix(game.CreatedAt, 0).UTC().Format("2006-01-02 15:04")
+	}
+	if page.Rules == "" {
+		page.Rules = "standard"
+	}
+
+	var last MoveDelta
+	for i, turn := range history {
+		move := ReplayMove{
+			Ply:      i + 1,
+			Notation: turn.Notation(),
+			Player:   turn.Player,
+			Current:  i+1 == ply,
+		}
+		if turn.Player == 1 || i == 0 {
+			move.Number = (state.startPly+i)/2 + 1
+		}
+		page.Moves = append(page.Moves, move)
+
+		if i < ply {
+			last = state.MakeMove(turn)
+		}
+	}
+	page.Position = state.Position()
+
+	for col := 0; col < state.Cols; col++ {
+		page.ColNames = append(page.ColNames, string(rune('a'+col)))
+	}
+	for row := state.Rows - 1; row >= 0; row-- {
+		cells := make([]ReplayCell, state.Cols)
+		for col := range cells {
+			cells[col] = ReplayCell{Player: state.Board[row][col], Dark: (row+col)%2 == 1}
+		}
+		page.Rows = append(page.Rows, ReplayRow{Name: row + 1, Cells: cells})
+	}
+
+	if ply > 0 {
+		turn := last.Turn
+		top := state.Rows - 1
+		page.Rows[top-turn.SourceRow].Cells[turn.SourceCol].Class = "last-from"
+		page.Rows[top-turn.DestRow].Cells[turn.DestCol].Class = "last-to"
+		if last.Captured != 0 && (last.CapturedRow != turn.DestRow || last.CapturedCol != turn.DestCol) {
+			// en passant, the captured pawn was not on the destination
+			page.Rows[top-last.CapturedRow].Cells[last.CapturedCol].Class = "captured"
+		}
+		page.LastMove = turn.Notation()
+		page.Capture = last.Captured != 0
+	}
+	return page, nil
+}
+
+func serveReplay(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
+
+	game, err := getGame(id)
+	if err != nil {
+		writeGameError(w, id, err)
+		return
+	}
+
+	ply := len(game.GameState.History)
+	if plyStr := r.URL.Query().Get("ply"); plyStr != "" {
+		ply, err = strconv.Atoi(plyStr)
+		if err != nil || ply < 0 || ply > len(game.GameState.History) {
+			writeError(w, ERR_BAD_REQUEST, fmt.Sprintf("Invalid ply, the game has %d plies", len(game.GameState.History)))
+			return
+		}
+	}
+
+	participants, err := DB_Get_Game_Participants(game)
+	if err != nil {
+		writeError(w, ERR_INTERNAL, "Error reading the players of the game")
+		return
+	}
+
+	page, err := NewReplayPage(game, participants, ply)
+	if err != nil {
+		slog.Error("Error replaying game", "id", id, "error", err)
+		writeError(w, ERR_INTERNAL, "Error replaying the game")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = replayTemplate.Execute(w, page)
+	if err != nil {
+		slog.Error("Error rendering replay", "id", id, "error", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// RuleVariant switches optional rules on top of the basic pawn-chess rules
+// (single step forward, diagonal captures, reaching the last row wins, no
+// moves is a draw). The zero value are the basic rules.
+type RuleVariant struct {
+	DoubleStep     bool `json:"doubleStep"`     // pawns on their home row may move two cells forward
+	EnPassant      bool `json:"enPassant"`      // a pawn passing by via a double step can be captured
+	WinOnNoPawns   bool `json:"winOnNoPawns"`   // a player without pawns loses
+	StalemateLoses bool `json:"stalemateLoses"` // a player without moves loses instead of a draw
+}
+
+// names used in the database, the position notation and the GAME_RULES
+// environment variable
+const (
+	RULE_DOUBLE_STEP     = "double_step"
+	RULE_EN_PASSANT      = "en_passant"
+	RULE_WIN_ON_NO_PAWNS = "win_on_no_pawns"
+	RULE_STALEMATE_LOSES = "stalemate_loses"
+)
+
+// defaultRules are used for all newly created games
+var defaultRules = RuleVariant{}
+
+func (r RuleVariant) IsStandard() bool {
+	return r == RuleVariant{}
+}
+
+// String returns the comma separated names of the enabled rules ("" for the
+// basic rules).
+func (r RuleVariant) String() string {
+	names := make([]string, 0)
+	if r.DoubleStep {
+		names = append(names, RULE_DOUBLE_STEP)
+	}
+	if r.EnPassant {
+		names = append(names, RULE_EN_PASSANT)
+	}
+	if r.WinOnNoPawns {
+		names = append(names, RULE_WIN_ON_NO_PAWNS)
+	}
+	if r.StalemateLoses {
+		names = append(names, RULE_STALEMATE_LOSES)
+	}
+	return strings.Join(names, ",")
+}
+
+func ParseRuleVariant(s string) (RuleVariant, error) {
+	rules := RuleVariant{}
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "", "standard":
+		case RULE_DOUBLE_STEP:
+			rules.DoubleStep = true
+		case RULE_EN_PASSANT:
+			rules.EnPassant = true
+		case RULE_WIN_ON_NO_PAWNS:
+			rules.WinOnNoPawns = true
+		case RULE_STALEMATE_LOSES:
+			rules.StalemateLoses = true
+		default:
+			return rules, fmt.Errorf("unknown rule %q", name)
+		}
+	}
+	if rules.EnPassant && !rules.DoubleStep {
+		return rules, fmt.Errorf("rule %s requires %s", RULE_EN_PASSANT, RULE_DOUBLE_STEP)
+	}
+	return rules, nil
+}
+
+// loadDefaultRules reads the rules for new games from the GAME_RULES
+// environment variable, e.g. GAME_RULES=double_step,en_passant
+func loadDefaultRules() {
+	rules, err := ParseRuleVariant(os.Getenv("GAME_RULES"))
+	if err != nil {
+		slog.Error("Invalid GAME_RULES, using the standard rules", "error", err)
+		return
+	}
+	defaultRules = rules
+	slog.Info("Rules for new games", "rules", rules.String())
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+)
+
+// initialGameState creates the state a game starts from: the standard setup
+// if start is empty, the given position otherwise.
+func initialGameState(rows int, cols int, rules RuleVariant, start string) (*GameState, error) {
+	if start == "" {
+		state := NewGameState(rows, cols)
+		state.Rules = rules
+		return &state, nil
+	}
+
+	state, err := ParsePosition(start)
+	if err != nil {
+		return nil, err
+	}
+	if state.Rows != rows || state.Cols != cols {
+		return nil, fmt.Errorf("start position is %dx%d, expected %dx%d", state.Rows, state.Cols, rows, cols)
+	}
+	state.Rules = rules
+	state.start = state.placement()
+	return state, nil
+}
+
+// NewGameStateFromPosition creates a game state from a custom starting
+// position (e.g. handicap setups) and checks that a game can start from it.
+// Rules given within the position string are kept.
+func NewGameStateFromPosition(position string) (*GameState, error) {
+	state, err := ParsePosition(position)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateStartPosition(state)
+	if err != nil {
+		return nil, err
+	}
+
+	state.start = state.placement()
+	return state, nil
+}
+
+func validateStartPosition(g *GameState) error {
+	for col := 0; col < g.Cols; col++ {
+		if g.Board[g.Rows-1][col] == 1 {
+			return fmt.Errorf("pawn of player 1 already on the last row")
+		}
+		if g.Board[0][col] == 2 {
+			return fmt.Errorf("pawn of player 2 already on the first row")
+		}
+	}
+	if g.countPawns(1) == 0 || g.countPawns(2) == 0 {
+		return fmt.Errorf("both players need at least one pawn")
+	}
+	if g.IsEnd() {
+		return fmt.Errorf("game would be over before the first move")
+	}
+	return nil
+}
@@ -9,8 +9,8 @@ document.addEventListener("DOMContentLoaded", () => {
     // Check if the page number is valid
     const apiUrl =
         pageNumber && !isNaN(pageNumber)
-            ? `/games/all?page=${pageNumber}`
-            : "/games/all?page=1";
+            ? `/api/v1/games?page=${pageNumber}`
+            : "/api/v1/games?page=1";
 
     // Fetch games from the API with the page parameter if valid
     fetch(apiUrl)
@@ -32,7 +32,8 @@ document.addEventListener("DOMContentLoaded", () => {
                 infoCell.innerHTML = `<a href="/game?id=${game.id}">
                     <span class="info-icon" title="More info about game ${game.id}">ℹ️</span>
                     ${game.id}
-                </a>`;
+                </a>
+                <a href="/replay/${game.id}" title="Replay game ${game.id}">▶</a>`;
 
                 const player1IdCell = document.createElement("td");
                 player1IdCell.textContent = game.player1_id;
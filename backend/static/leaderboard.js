@@ -1,4 +1,39 @@
-const renderLeaderboard = (users) => {
+const escapeHtml = (text) =>
+    String(text).replace(
+        /[&<>"']/g,
+        (c) =>
+            ({
+                "&": "&amp;",
+                "<": "&lt;",
+                ">": "&gt;",
+                '"': "&quot;",
+                "'": "&#39;",
+            })[c]
+    );
+
+const renderTeams = (teams, config) => {
+    document.getElementById("teamRule").textContent = config.allow_same_team_games
+        ? "Bots of the same team play each other."
+        : "Bots of the same team are not paired.";
+
+    const tbody = document.querySelector("#teamTable tbody");
+    tbody.innerHTML = teams
+        .map(
+            (team) => `
+             <tr>
+                 <td>${team.rank}</td>
+                 <td>${escapeHtml(team.name)}</td>
+                 <td>${team.average_elo.toFixed(0)}</td>
+                 <td>${team.best_elo}</td>
+                 <td>${team.active_members} / ${team.members}</td>
+                 <td>${team.results.wins} / ${team.results.draws} / ${team.results.losses}</td>
+             </tr>
+         `
+        )
+        .join("");
+};
+
+const renderLeaderboard = (users, teamNames) => {
     console.log(users);
     // Sort users by current ELO
     users.sort((a, b) => b.current_elo - a.current_elo);
@@ -31,6 +66,7 @@ const renderLeaderboard = (users) => {
                  <td>${player.id}</td>
                  <td>${player.current_elo}</td>
                  <td>${player.name}</td>
+                 <td>${player.team_id ? escapeHtml(teamNames[player.team_id] ?? "") : ""}</td>
                  <td>${lastFiveGames}</td>
                  <td>${winPercentage}% / ${drawPercentage}% / ${lossPercentage}%</td>
                  <td>${totalGames}</td>
@@ -48,11 +84,15 @@ const renderLeaderboard = (users) => {
 };
 
 const onLoad = () => {
-    fetch("/users")
-        .then((response) => response.json())
-        .then((users) => {
-            renderLeaderboard(users);
-        });
+    Promise.all([
+        fetch("/api/v1/players").then((response) => response.json()),
+        fetch("/api/v1/teams").then((response) => response.json()),
+        fetch("/api/v1/config/teams").then((response) => response.json()),
+    ]).then(([users, teams, config]) => {
+        const teamNames = Object.fromEntries(teams.map((team) => [team.id, team.name]));
+        renderLeaderboard(users, teamNames);
+        renderTeams(teams, config);
+    });
 };
 document.addEventListener("DOMContentLoaded", () => {
     // // const users = [
@@ -1,56 +1,117 @@
-const renderTable = (player) => {
-    if (player) {
-        // Populate player info
-        document.getElementById("player-name").textContent = player.name;
-        document.getElementById(
-            "player-elo"
-        ).textContent = `Current ELO: ${player.current_elo}`;
-
-        // Populate game history table
-        const tbody = document.querySelector("#gameHistoryTable tbody");
-        const reversedHistory = [...player.game_history].reverse();
-
-        reversedHistory.forEach((game, index) => {
-            const eloDiff =
-                index === reversedHistory.length - 1
-                    ? "+0"
-                    : game.elo - reversedHistory[index + 1].elo;
-            const eloDiffSign = eloDiff > 0 ? "+" : "";
-
-            const result = game.win ? "W" : game.draw ? "D" : "L";
-
-            const row = `
+const escapeHtml = (text) =>
+    String(text).replace(
+        /[&<>"']/g,
+        (c) =>
+            ({
+                "&": "&amp;",
+                "<": "&lt;",
+                ">": "&gt;",
+                '"': "&quot;",
+                "'": "&#39;",
+            })[c]
+    );
+
+const resultCells = (stats) => `
+    <td>${stats.games}</td>
+    <td>${stats.wins} / ${stats.draws} / ${stats.losses}</td>
+    <td>${(stats.score * 100).toFixed(1)}%</td>
+`;
+
+const fillTable = (id, rows) => {
+    document.querySelector(`#${id} tbody`).innerHTML = rows.join("");
+};
+
+const renderRating = (profile) => {
+    const chart = document.getElementById("rating-chart");
+    // ratings start at START_ELO (1000)
+    const elos = [1000, ...profile.rating.map((point) => point.elo)];
+    const min = Math.min(...elos) - 10;
+    const max = Math.max(...elos) + 10;
+    const points = elos.map((elo, i) => {
+        const x = elos.length > 1 ? (i / (elos.length - 1)) * 600 : 0;
+        const y = 200 - ((elo - min) / (max - min)) * 200;
+        return `${x.toFixed(1)},${y.toFixed(1)}`;
+    });
+    chart.innerHTML = `<polyline points="${points.join(" ")}" />`;
+};
+
+const renderProfile = (profile) => {
+    if (!profile) {
+        document.querySelector(".container").innerHTML =
+            "<h2>Player not found</h2>";
+        return;
+    }
+
+    // Populate player info
+    document.getElementById("player-name").textContent = profile.name;
+    document.getElementById(
+        "player-elo"
+    ).textContent = `Current ELO: ${profile.current_elo}`;
+    const results = profile.results;
+    document.getElementById("player-results").textContent =
+        `${results.games} rated games: ${results.wins} wins, ${results.draws} draws, ${results.losses} losses, ` +
+        `average length ${profile.average_game_length.toFixed(1)} plies`;
+
+    renderRating(profile);
+
+    fillTable(
+        "versionTable",
+        profile.versions.map((version) => {
+            const name = version.version ? escapeHtml(version.version) : "<i>unversioned</i>";
+            const change = version.elo_change > 0 ? `+${version.elo_change}` : `${version.elo_change}`;
+            return `<tr><td>${name}${version.current ? " (current)" : ""}</td><td>${version.elo}</td><td>${change} (from ${version.seed_elo})</td>${resultCells(version.results)}</tr>`;
+        })
+    );
+    fillTable(
+        "boardTable",
+        profile.by_board.map(
+            (stats) => `<tr><td>${stats.rows}x${stats.cols}</td>${resultCells(stats)}</tr>`
+        )
+    );
+    fillTable(
+        "colorTable",
+        profile.by_color.map(
+            (stats) =>
+                `<tr><td>${stats.player === 1 ? "Player 1 (first)" : "Player 2 (second)"}</td>${resultCells(stats)}</tr>`
+        )
+    );
+    fillTable(
+        "opponentTable",
+        profile.opponents.map(
+            (stats) =>
+                `<tr><td><a href="/player?id=${stats.opponent_id}">${escapeHtml(stats.opponent_name)}</a></td>${resultCells(stats)}</tr>`
+        )
+    );
+    fillTable(
+        "gameHistoryTable",
+        profile.recent_games.map(
+            (game) => `
                 <tr>
                     <td>
-                        <a href="/game?id=${game.id}">
-                            <span class="info-icon" title="More info about game ${game.id}">ℹ️</span>
-                            ${game.id}
+                        <a href="/replay/${game.game_id}">
+                            <span class="info-icon" title="Replay game ${game.game_id}">ℹ️</span>
+                            ${game.game_id}
                         </a>
-                     </td>
                     </td>
-                    <td>${result}</td>
-                    <td>${eloDiffSign}${eloDiff}</td>
-                    <td>${game.elo}</td>
+                    <td><a href="/player?id=${game.opponent_id}">${escapeHtml(game.opponent_name)}</a></td>
+                    <td>${game.rows}x${game.cols}</td>
+                    <td>${game.plies}</td>
+                    <td>${game.result}${game.termination ? ` (${game.termination})` : ""}</td>
                 </tr>
-            `;
-
-            tbody.insertAdjacentHTML("beforeend", row);
-        });
-    } else {
-        document.querySelector(".container").innerHTML =
-            "<h2>Player not found</h2>";
-    }
+            `
+        )
+    );
 };
 
 const onLoad = () => {
+    // players are selected by ?id=... or ?name=...
     const urlParams = new URLSearchParams(window.location.search);
-    const playerId = parseInt(urlParams.get("id"), 10);
-
-    fetch("/users")
-        .then((response) => response.json())
-        .then((users) => {
-            const player = users.find((user) => user.id === playerId);
-            renderTable(player);
-        });
+    const url = urlParams.has("name")
+        ? `/api/v1/players/by-name/${encodeURIComponent(urlParams.get("name"))}/profile`
+        : `/api/v1/players/${parseInt(urlParams.get("id"), 10)}/profile`;
+
+    fetch(url)
+        .then((response) => (response.ok ? response.json() : null))
+        .then(renderProfile);
 };
 document.addEventListener("DOMContentLoaded", onLoad);
@@ -2,7 +2,7 @@ let originalGameState = null;
 
 // Fetch game state by ID
 async function fetchGameState(id) {
-    const response = await fetch(`/game/${id}/state`);
+    const response = await fetch(`/api/v1/games/${id}`);
     if (response.ok) {
         return await response.json();
     } else {
@@ -91,18 +91,49 @@ function renderSlider(history) {
     };
 }
 
+// Parse the board of a position string like "5x3 ppp/3/3/3/PPP 1"
+// (rows are listed from the last row down to row 0)
+function boardFromPosition(position) {
+    const ranks = position.split(" ")[1].split("/");
+    return ranks.reverse().map((rank) => {
+        const row = [];
+        for (const token of rank.match(/\d+|[Pp]/g)) {
+            if (token === "P") {
+                row.push(1);
+            } else if (token === "p") {
+                row.push(2);
+            } else {
+                row.push(...Array(parseInt(token)).fill(0));
+            }
+        }
+        return row;
+    });
+}
+
 // Reconstruct the game state based on a subset of history
 function reconstructGameState(partialHistory) {
     // Clone the original state
     const newState = JSON.parse(JSON.stringify(originalGameState));
-    newState.board = newState.board.map((row) => row.map(() => 0));
-    for (let col = 0; col < newState.cols; col++) {
-        newState.board[0][col] = 1;
-        newState.board[newState.rows - 1][col] = 2;
+    if (newState.start) {
+        // game started from a custom position
+        newState.board = boardFromPosition(newState.start);
+    } else {
+        newState.board = newState.board.map((row) => row.map(() => 0));
+        for (let col = 0; col < newState.cols; col++) {
+            newState.board[0][col] = 1;
+            newState.board[newState.rows - 1][col] = 2;
+        }
     }
 
     // Apply each turn to the board
     partialHistory.forEach((turn) => {
+        // diagonal move to an empty cell: en passant capture
+        if (
+            turn.sourceCol !== turn.destCol &&
+            newState.board[turn.destRow][turn.destCol] === 0
+        ) {
+            newState.board[turn.sourceRow][turn.destCol] = 0;
+        }
         newState.board[turn.sourceRow][turn.sourceCol] = 0;
         newState.board[turn.destRow][turn.destCol] = turn.player;
     });
@@ -113,6 +144,51 @@ function reconstructGameState(partialHistory) {
     renderBoard(newState.board, newState.rows, newState.cols);
 }
 
+function renderStatus(game) {
+    const status = document.getElementById("statusSpan");
+    if (game.outcome === 0) {
+        status.textContent = `running, player ${game.game_state.currentPlayer} to move`;
+    } else if (game.outcome === -1) {
+        status.textContent = `draw (${game.termination})`;
+    } else {
+        status.textContent = `player ${game.outcome} won (${game.termination})`;
+    }
+}
+
+// Follow a running game via its event stream
+function followGame(game) {
+    const source = new EventSource(`/api/v1/games/${game.id}/events`);
+
+    source.addEventListener("move", (event) => {
+        const move = JSON.parse(event.data);
+        const history = originalGameState.history;
+        // moves may be contained in the snapshot already
+        if (move.turn.turnID <= history.length) {
+            return;
+        }
+
+        // keep the replay position unless it shows the latest move
+        const slider = document.getElementById("history-range");
+        const atEnd = parseInt(slider.value) === history.length;
+
+        history.push(move.turn);
+        originalGameState.currentPlayer = move.current_player;
+        renderHistory(history);
+        slider.max = history.length;
+        if (atEnd) {
+            slider.value = history.length;
+            reconstructGameState(history);
+        }
+        renderStatus({ ...game, game_state: originalGameState });
+    });
+
+    source.addEventListener("end", (event) => {
+        const end = JSON.parse(event.data);
+        renderStatus({ ...game, ...end });
+        source.close();
+    });
+}
+
 // Load game state and render everything
 async function loadGameState() {
     const urlParams = new URLSearchParams(window.location.search);
@@ -135,6 +211,11 @@ async function loadGameState() {
         renderBoard(gameState.board, gameState.rows, gameState.cols);
         renderHistory(gameState.history);
         renderSlider(gameState.history);
+        renderStatus(game);
+
+        if (game.outcome === 0) {
+            followGame(game);
+        }
     }
 }
 
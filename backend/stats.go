@@ -0,0 +1,124 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	STATS_MATRIX_DEFAULT = 10
+	STATS_MATRIX_MAX     = 50
+	STATS_CONFIDENCE_Z   = 1.96 // 95% confidence
+)
+
+type PlayerRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Elo  int    `json:"elo"`
+}
+
+// HeadToHead are the results between two players from the view of Player1.
+type HeadToHead struct {
+	Player1 PlayerRef    `json:"player1"`
+	Player2 PlayerRef    `json:"player2"`
+	Results ResultCounts `json:"results"`
+	// Wilson score interval of the score, draws count as half a win
+	ScoreInterval [2]float64   `json:"score_interval"`
+	ByBoard       []BoardStats `json:"by_board"`
+}
+
+// ResultMatrix holds the results of every pair of players. Results[i][j] is
+// from the view of Players[i] against Players[j].
+type ResultMatrix struct {
+	Players []PlayerRef      `json:"players"`
+	Results [][]ResultCounts `json:"results"`
+}
+
+// scoreInterval returns the Wilson score interval for the score of the given
+// number of games.
+func scoreInterval(score float64, games int) [2]float64 {
+	if games == 0 {
+		return [2]float64{0, 1}
+	}
+	n := float64(games)
+	z2 := STATS_CONFIDENCE_Z * STATS_CONFIDENCE_Z
+	center := (score + z2/(2*n)) / (1 + z2/n)
+	half := STATS_CONFIDENCE_Z * math.Sqrt(score*(1-score)/n+z2/(4*n*n)) / (1 + z2/n)
+	return [2]float64{math.Max(0, center-half), math.Min(1, center+half)}
+}
+
+func queryPlayerID(r *http.Request, key string) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, errors.New("parameter " + key + " is required")
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("parameter " + key + " is not a player id")
+	}
+	return id, nil
+}
+
+func serveHeadToHead(w http.ResponseWriter, r *http.Request) {
+	p1, err := queryPlayerID(r, "p1")
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, err.Error())
+		return
+	}
+	p2, err := queryPlayerID(r, "p2")
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, err.Error())
+		return
+	}
+	if p1 == p2 {
+		writeError(w, ERR_BAD_REQUEST, "p1 and p2 have to be different players")
+		return
+	}
+
+	h2h, err := DB_Get_Head_To_Head(p1, p2)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_NOT_FOUND, "Player not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Error reading head to head statistics", "p1", p1, "p2", p2, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h2h)
+}
+
+// serveResultMatrix returns the results between the top n players by rating.
+func serveResultMatrix(w http.ResponseWriter, r *http.Request) {
+	n := STATS_MATRIX_DEFAULT
+	if value := r.URL.Query().Get("n"); value != "" {
+		var err error
+		n, err = strconv.Atoi(value)
+		if err != nil || n < 1 || n > STATS_MATRIX_MAX {
+			writeError(w, ERR_BAD_REQUEST, "n has to be between 1 and "+strconv.Itoa(STATS_MATRIX_MAX))
+			return
+		}
+	}
+
+	matrix, err := DB_Get_Result_Matrix(n)
+	if err != nil {
+		slog.Error("Error reading result matrix", "n", n, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matrix)
+}
+
+func InitHttpHandler_Stats() {
+	mux.HandleFunc("GET /stats/h2h", deprecated("/stats/h2h", serveHeadToHead))
+	mux.HandleFunc("GET /stats/matrix", deprecated("/stats/matrix", serveResultMatrix))
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// Teams group bots, e.g. of the people of one lab. A team is managed by the
+// owner that created it, bots join with the join code of the team. Teams are
+// ranked by the average rating of their active bots, their results only
+// count games against bots of other teams (or without team).
+
+// allowSameTeamGames decides whether bots of the same team are paired in
+// rated games, see ALLOW_SAME_TEAM_GAMES. Custom games are unrated and not
+// restricted.
+var allowSameTeamGames = true
+
+type Team struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ManagerID int    `json:"manager_id"`
+	JoinCode  string `json:"join_code,omitempty"` // only shown to the manager
+	CreatedAt int64  `json:"created_at"`
+}
+
+type TeamStanding struct {
+	Rank          int          `json:"rank"`
+	ID            int          `json:"id"`
+	Name          string       `json:"name"`
+	Members       int          `json:"members"`
+	ActiveMembers int          `json:"active_members"`
+	AverageElo    float64      `json:"average_elo"` // of the active members
+	BestElo       int          `json:"best_elo"`
+	Results       ResultCounts `json:"results"` // against other teams
+}
+
+type TeamMember struct {
+	PlayerRef
+	Retired bool         `json:"retired"`
+	Results ResultCounts `json:"results"`
+}
+
+type TeamDetails struct {
+	TeamStanding
+	Players []TeamMember `json:"players"`
+}
+
+func loadTeamConfig() {
+	value := os.Getenv("ALLOW_SAME_TEAM_GAMES")
+	if value == "" {
+		return
+	}
+	allow, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Error("Invalid ALLOW_SAME_TEAM_GAMES, allowing games within teams", "value", value)
+		return
+	}
+	allowSameTeamGames = allow
+	slog.Info("Team config loaded", "allowSameTeamGames", allow)
+}
+
+func serveTeamStandings(w http.ResponseWriter, _ *http.Request) {
+	standings, err := DB_Get_Team_Standings()
+	if err != nil {
+		slog.Error("Error reading team standings", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(standings)
+}
+
+func serveTeam(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
+
+	standings, err := DB_Get_Team_Standings()
+	if err != nil {
+		slog.Error("Error reading team standings", "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	var details *TeamDetails
+	for _, standing := range standings {
+		if standing.ID == id {
+			details = &TeamDetails{TeamStanding: standing}
+		}
+	}
+	if details == nil {
+		writeError(w, ERR_NOT_FOUND, "Team not found")
+		return
+	}
+
+	details.Players, err = DB_Get_Team_Members(id)
+	if err != nil {
+		slog.Error("Error reading team members", "team", id, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
+
+func serveTeamConfig(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"allow_same_team_games": allowSameTeamGames})
+}
+
+func serveOwnerCreateTeam(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input")
+		return
+	}
+	name, err := checkPlayerName(request.Name)
+	if err != nil {
+		writeError(w, ERR_BAD_REQUEST, err.Error())
+		return
+	}
+
+	team, err := DB_Create_Team(name, owner.ID)
+	if errors.Is(err, ErrNameTaken) {
+		writeError(w, ERR_NAME_TAKEN, "Name is already taken")
+		return
+	}
+	if err != nil {
+		slog.Error("Error creating team", "owner", owner.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(team)
+}
+
+func serveOwnerTeams(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+
+	teams, err := DB_Get_Managed_Teams(owner.ID)
+	if err != nil {
+		slog.Error("Error reading teams", "owner", owner.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(teams)
+}
+
+// serveOwnerJoinTeam moves a bot of the owner to the team of the join code
+// of the body, e.g. {"join_code": "..."}.
+func serveOwnerJoinTeam(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+	player := ownedBot(w, r, owner)
+	if player == nil {
+		return
+	}
+
+	var request struct {
+		JoinCode string `json:"join_code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.JoinCode == "" {
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input, join_code is required")
+		return
+	}
+
+	team, err := DB_Join_Team(player.ID, request.JoinCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_NOT_FOUND, "Invalid join code")
+		return
+	}
+	if err != nil {
+		slog.Error("Error joining team", "player", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
+
+func serveOwnerLeaveTeam(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+	player := ownedBot(w, r, owner)
+	if player == nil {
+		return
+	}
+
+	err := DB_Leave_Team(player.ID, 0)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_NOT_FOUND, "Bot is not in a team")
+		return
+	}
+	if err != nil {
+		slog.Error("Error leaving team", "player", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveOwnerRemoveMember lets the manager of a team remove any bot from it.
+func serveOwnerRemoveMember(w http.ResponseWriter, r *http.Request) {
+	owner := authenticateOwner(w, r)
+	if owner == nil {
+		return
+	}
+
+	teamID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
+	playerID, err := strconv.Atoi(r.PathValue("playerID"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid player ID")
+		return
+	}
+
+	teams, err := DB_Get_Managed_Teams(owner.ID)
+	if err != nil {
+		slog.Error("Error reading teams", "owner", owner.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	managed := false
+	for _, team := range teams {
+		managed = managed || team.ID == teamID
+	}
+	if !managed {
+		writeError(w, ERR_NOT_FOUND, "Team not found")
+		return
+	}
+
+	err = DB_Leave_Team(playerID, teamID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_NOT_FOUND, "Bot is not in the team")
+		return
+	}
+	if err != nil {
+		slog.Error("Error removing team member", "team", teamID, "player", playerID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func InitHttpHandler_Teams() {
+	mux.HandleFunc("GET /teams", deprecated("/teams", serveTeamStandings))
+	mux.HandleFunc("GET /teams/{id}", deprecated("/teams/{id}", serveTeam))
+	mux.HandleFunc("GET /config/teams", deprecated("/config/teams", serveTeamConfig))
+	mux.HandleFunc("POST /owner/teams", deprecated("/owner/teams", serveOwnerCreateTeam))
+	mux.HandleFunc("GET /owner/teams", deprecated("/owner/teams", serveOwnerTeams))
+	mux.HandleFunc("DELETE /owner/teams/{id}/members/{playerID}", deprecated("/owner/teams/{id}/members/{playerID}", serveOwnerRemoveMember))
+	mux.HandleFunc("PUT /owner/bots/{id}/team", deprecated("/owner/bots/{id}/team", serveOwnerJoinTeam))
+	mux.HandleFunc("DELETE /owner/bots/{id}/team", deprecated("/owner/bots/{id}/team", serveOwnerLeaveTeam))
+}
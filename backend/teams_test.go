@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestCustomGamesWithinTeam allows unrated games between bots of the same
+// team, also if rated games within teams are not.
+func TestCustomGamesWithinTeam(t *testing.T) {
+	s := newSpecTest(t)
+	allow := allowSameTeamGames
+	allowSameTeamGames = false
+	t.Cleanup(func() { allowSameTeamGames = allow })
+
+	owner, err := DB_Create_Owner("owner", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := DB_Create_Team("team", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alpha, token, err := DB_Create_Player("alpha", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	beta, _, err := DB_Create_Player("beta", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{alpha, beta} {
+		if _, err := DB_Join_Team(id, team.JoinCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := fmt.Sprintf(`{"opponent_id":%d,"position":"5x5 ppppp/5/5/5/PPPPP 1"}`, beta)
+	game := decode[Game](t, s.expect(http.StatusCreated, "POST", "/games", token, request))
+	if !game.Unrated {
+		t.Error("custom game is rated")
+	}
+}
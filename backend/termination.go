@@ -0,0 +1,39 @@
+package main
+
+// termination reasons stored with finished games
+const (
+	// decided on the board
+	TERMINATION_BREAKTHROUGH = "breakthrough"
+	TERMINATION_NO_PAWNS     = "no pawns left"
+	TERMINATION_STALEMATE    = "stalemate"
+
+	// decided off the board
+	TERMINATION_RESIGNATION  = "resignation"
+	TERMINATION_AGREEMENT    = "agreement"
+	TERMINATION_TIMEOUT      = "timeout"
+	TERMINATION_ADJUDICATION = "adjudication"
+)
+
+// terminationReason describes why the game state ended ("" while running).
+func terminationReason(g *GameState) string {
+	if g.reachedLastRow() != 0 {
+		return TERMINATION_BREAKTHROUGH
+	}
+	if g.winnerOnBoard() != 0 {
+		return TERMINATION_NO_PAWNS
+	}
+	if !g.HasMoves() {
+		return TERMINATION_STALEMATE
+	}
+	return ""
+}
+
+// isOffBoardTermination reports whether the reason ends a game in a position
+// that is not final according to the rules.
+func isOffBoardTermination(reason string) bool {
+	switch reason {
+	case TERMINATION_RESIGNATION, TERMINATION_AGREEMENT, TERMINATION_TIMEOUT, TERMINATION_ADJUDICATION:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Bots that stop playing would block their games forever. Once MOVE_TIMEOUT
+// has passed since the last move (or the creation of the game), the periodic
+// job ends the game as a loss of the player to move. The timeout is disabled
+// by default.
+var moveTimeout time.Duration
+
+func loadTimeoutConfig() {
+	value := os.Getenv("MOVE_TIMEOUT")
+	if value == "" {
+		return
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		slog.Error("Invalid MOVE_TIMEOUT, games do not time out", "value", value)
+		return
+	}
+	moveTimeout = timeout
+	slog.Info("Timeout config loaded", "moveTimeout", timeout)
+}
+
+// moveDeadline returns the unix time at which the player to move loses.
+func (game *Game) moveDeadline(timeout time.Duration) int64 {
+	return max(game.CreatedAt, game.LastMoveAt) + int64(timeout/time.Second)
+}
+
+// timeoutGames ends the active games whose player to move exceeded the
+// timeout at the given time.
+func timeoutGames(now time.Time) error {
+	if moveTimeout <= 0 {
+		return nil
+	}
+
+	var games []Game
+	if activeGames.Loaded() {
+		games = activeGames.GetAll()
+	} else {
+		var err error
+		games, err = DB_Get_Active_Games()
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range games {
+		game := &games[i]
+		if now.Unix() < game.moveDeadline(moveTimeout) {
+			continue
+		}
+
+		loser := game.GameState.NextPlayer()
+		err := endGame(game, 3-loser, TERMINATION_TIMEOUT)
+		if err != nil {
+			// the player moved meanwhile
+			slog.Warn("Error ending game on time", "id", game.ID, "error", err)
+			continue
+		}
+		slog.Info("Game ended on time", "id", game.ID, "loser", loser, "plies", len(game.GameState.History))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeoutGames(t *testing.T) {
+	discardLogs(t)
+	openTestDB(t)
+
+	timeout := moveTimeout
+	moveTimeout = time.Minute
+	t.Cleanup(func() { moveTimeout = timeout })
+
+	p1, _, err := DB_Create_Player("alpha", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, _, err := DB_Create_Player("beta", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := NewGameState(5, 5)
+	id, err := DB_Create_Game(p1, p2, &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyAction(Player{ID: p1}, id, firstMove(t, id)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clock runs from the last move
+	game, err := DB_Get_Game(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.LastMoveAt < game.CreatedAt {
+		t.Fatalf("last move at %d, before the creation at %d", game.LastMoveAt, game.CreatedAt)
+	}
+	if err := timeoutGames(time.Unix(game.LastMoveAt, 0).Add(moveTimeout - time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if game, _ := DB_Get_Game(id); game.Outcome != 0 {
+		t.Fatalf("game ended before the timeout: %+v", game)
+	}
+
+	if err := timeoutGames(time.Unix(game.LastMoveAt, 0).Add(moveTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	game, err = DB_Get_Game(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Outcome != 1 || game.Termination != TERMINATION_TIMEOUT {
+		t.Errorf("outcome %d by %q, want a win of player 1 on time", game.Outcome, game.Termination)
+	}
+	if !isOffBoardTermination(game.Termination) {
+		t.Error("timeout is not an off-board termination")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// API tokens are random strings handed out once, at sign up or rotation. The
+// database only keeps their SHA-256 hash. Clients send them in the
+// Authorization header (Bearer), the token query parameter and the tokens in
+// the paths of the older endpoints are still accepted but deprecated.
+const (
+	TOKEN_BYTES         = 24 // 32 characters in base64
+	TOKEN_PREFIX_LENGTH = 6
+	TOKEN_REDACTED      = "REDACTED"
+)
+
+// ApiToken describes a token of a player without the token itself.
+type ApiToken struct {
+	ID        int    `json:"id"`
+	Prefix    string `json:"prefix"`
+	CreatedAt int64  `json:"created_at"`
+	RevokedAt int64  `json:"revoked_at"`
+	Active    bool   `json:"active"`
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, TOKEN_BYTES)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenPrefix returns the first characters of the token, enough to tell the
+// tokens of a player apart but useless to authenticate.
+func tokenPrefix(token string) string {
+	if len(token) <= TOKEN_PREFIX_LENGTH {
+		return ""
+	}
+	return token[:TOKEN_PREFIX_LENGTH]
+}
+
+// requestToken returns the token of the request. The Authorization header
+// takes precedence over the deprecated token query parameter and path values.
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if token := r.PathValue("userToken"); token != "" {
+		return token
+	}
+	return r.PathValue("token")
+}
+
+// playerByToken returns the player of the token. The errors are sent to the
+// client as they are.
+func playerByToken(token string) (*Player, error) {
+	if token == "" {
+		return nil, NewAPIError(ERR_UNAUTHORIZED, "Token is required for authorization.")
+	}
+
+	player, err := DB_Get_Player_by_Token(token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NewAPIError(ERR_INVALID_TOKEN, "Invalid token (unknown or revoked)")
+	}
+	if err != nil {
+		slog.Error("Error authenticating player", "error", err)
+		return nil, NewAPIError(ERR_INTERNAL, "Internal server error")
+	}
+	return player, nil
+}
+
+// authenticate returns the player of the request token, or writes the error
+// response and returns nil.
+func authenticate(w http.ResponseWriter, r *http.Request) *Player {
+	player, err := playerByToken(requestToken(r))
+	if err != nil {
+		apiErr := apiError(err)
+		if apiErr.Code.Status() == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		writeAPIError(w, apiErr)
+		return nil
+	}
+	return player
+}
+
+// redactedURL returns the URL of the request with all tokens replaced, such
+// that it can be logged.
+func redactedURL(r *http.Request) string {
+	u := *r.URL
+	if query := u.Query(); query.Has("token") {
+		query.Set("token", TOKEN_REDACTED)
+		u.RawQuery = query.Encode()
+	}
+	for _, name := range []string{"userToken", "token"} {
+		if token := r.PathValue(name); token != "" {
+			u.Path = strings.Replace(u.Path, token, TOKEN_REDACTED, 1)
+			u.RawPath = ""
+		}
+	}
+	return u.String()
+}
+
+// loadLegacyTokens hashes the plain text tokens of older databases.
+func loadLegacyTokens() {
+	count, err := DB_Hash_Legacy_Tokens()
+	if err != nil {
+		slog.Error("Error hashing legacy tokens", "error", err)
+		return
+	}
+	if count > 0 {
+		slog.Info("Legacy tokens hashed", "count", count)
+	}
+}
+
+// NewToken is a token that was just created, it is shown only once.
+type NewToken struct {
+	ID    int    `json:"id"`
+	Token string `json:"token"`
+}
+
+func writeNewToken(w http.ResponseWriter, id int, token string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NewToken{ID: id, Token: token})
+}
+
+func serveListTokens(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
+
+	tokens, err := DB_Get_Tokens(player.ID)
+	if err != nil {
+		slog.Error("Error reading tokens", "playerID", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// serveCreateToken creates an additional token, e.g. for a second client.
+func serveCreateToken(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
+
+	token, id, err := DB_Create_Token(player.ID)
+	if err != nil {
+		slog.Error("Error creating token", "playerID", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	writeNewToken(w, id, token)
+}
+
+// serveRotateToken replaces the token of the request by a new one.
+func serveRotateToken(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
+
+	token, id, err := DB_Rotate_Token(requestToken(r))
+	if errors.Is(err, sql.ErrNoRows) {
+		// revoked concurrently
+		writeError(w, ERR_INVALID_TOKEN, "Invalid token (token was revoked)")
+		return
+	}
+	if err != nil {
+		slog.Error("Error rotating token", "playerID", player.ID, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	writeNewToken(w, id, token)
+}
+
+func serveRevokeToken(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, ERR_INVALID_ID, "Invalid ID")
+		return
+	}
+
+	err = DB_Revoke_Token(player.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, ERR_NOT_FOUND, "Token not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Error revoking token", "playerID", player.ID, "tokenID", id, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func InitHttpHandler_Tokens() {
+	mux.HandleFunc("GET /user/tokens", deprecated("/players/me/tokens", serveListTokens))
+	mux.HandleFunc("POST /user/tokens", deprecated("/players/me/tokens", serveCreateToken))
+	mux.HandleFunc("POST /user/token/rotate", deprecated("/players/me/tokens/rotate", serveRotateToken))
+	mux.HandleFunc("DELETE /user/tokens/{id}", deprecated("/players/me/tokens/{id}", serveRevokeToken))
+}
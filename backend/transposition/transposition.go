@@ -0,0 +1,79 @@
+// Package transposition provides a fixed size hash table for search engines,
+// keyed by Zobrist hashes.
+package transposition
+
+// Entry is a single slot of the table.
+type Entry[V any] struct {
+	Key   uint64
+	Depth int
+	Value V
+	used  bool
+}
+
+// Table maps position hashes to values. Collisions on the same slot are
+// resolved by keeping the entry that was searched deeper. A Table is not safe
+// for concurrent use.
+type Table[V any] struct {
+	entries []Entry[V]
+	mask    uint64
+
+	Hits   uint64
+	Misses uint64
+	Stores uint64
+}
+
+// New creates a table with 2^bits slots.
+func New[V any](bits uint) *Table[V] {
+	if bits > 30 {
+		bits = 30
+	}
+	size := uint64(1) << bits
+	return &Table[V]{
+		entries: make([]Entry[V], size),
+		mask:    size - 1,
+	}
+}
+
+func (t *Table[V]) slot(key uint64) *Entry[V] {
+	return &t.entries[key&t.mask]
+}
+
+// Get returns the value and the depth it was stored with.
+func (t *Table[V]) Get(key uint64) (V, int, bool) {
+	e := t.slot(key)
+	if e.used && e.Key == key {
+		t.Hits++
+		return e.Value, e.Depth, true
+	}
+	t.Misses++
+	var zero V
+	return zero, 0, false
+}
+
+// Put stores the value unless the slot holds a different position that was
+// searched deeper.
+func (t *Table[V]) Put(key uint64, depth int, value V) {
+	e := t.slot(key)
+	if e.used && e.Key != key && e.Depth > depth {
+		return
+	}
+	*e = Entry[V]{Key: key, Depth: depth, Value: value, used: true}
+	t.Stores++
+}
+
+// Len returns the number of used slots.
+func (t *Table[V]) Len() int {
+	n := 0
+	for i := range t.entries {
+		if t.entries[i].used {
+			n++
+		}
+	}
+	return n
+}
+
+// Clear removes all entries and resets the statistics.
+func (t *Table[V]) Clear() {
+	clear(t.entries)
+	t.Hits, t.Misses, t.Stores = 0, 0, 0
+}
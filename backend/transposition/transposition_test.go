@@ -0,0 +1,84 @@
+package transposition
+
+import "testing"
+
+func TestPutGet(t *testing.T) {
+	table := New[string](4)
+	if _, _, ok := table.Get(42); ok {
+		t.Fatal("empty table has an entry")
+	}
+
+	table.Put(42, 3, "a")
+	value, depth, ok := table.Get(42)
+	if !ok || value != "a" || depth != 3 {
+		t.Fatalf("got %q, depth %d, %v", value, depth, ok)
+	}
+
+	// same slot, different position
+	if _, _, ok := table.Get(42 + 16); ok {
+		t.Error("entry found for a different key of the same slot")
+	}
+
+	// the same position is always updated, also with a lower depth
+	table.Put(42, 1, "b")
+	if value, depth, _ := table.Get(42); value != "b" || depth != 1 {
+		t.Errorf("update of the position: got %q, depth %d", value, depth)
+	}
+
+	if table.Hits != 2 || table.Misses != 2 || table.Stores != 2 || table.Len() != 1 {
+		t.Errorf("hits %d, misses %d, stores %d, len %d", table.Hits, table.Misses, table.Stores, table.Len())
+	}
+}
+
+func TestReplacement(t *testing.T) {
+	table := New[int](2)
+
+	// a shallower position does not replace a deeper one
+	table.Put(1, 5, 10)
+	table.Put(1+4, 4, 20)
+	if _, _, ok := table.Get(1 + 4); ok {
+		t.Error("shallower entry replaced a deeper one")
+	}
+	if value, _, ok := table.Get(1); !ok || value != 10 {
+		t.Errorf("deeper entry lost: %d, %v", value, ok)
+	}
+
+	// equal or deeper ones do
+	table.Put(1+4, 5, 20)
+	if value, _, ok := table.Get(1 + 4); !ok || value != 20 {
+		t.Errorf("entry of the same depth was not stored: %d, %v", value, ok)
+	}
+	table.Put(1+8, 6, 30)
+	if value, _, ok := table.Get(1 + 8); !ok || value != 30 {
+		t.Errorf("deeper entry was not stored: %d, %v", value, ok)
+	}
+	if _, _, ok := table.Get(1 + 4); ok {
+		t.Error("replaced entry is still found")
+	}
+
+	// the slots are independent
+	table.Put(2, 0, 40)
+	if table.Len() != 2 {
+		t.Errorf("%d used slots, want 2", table.Len())
+	}
+
+	table.Clear()
+	if _, _, ok := table.Get(2); ok || table.Len() != 0 {
+		t.Error("cleared table has entries")
+	}
+	if table.Hits != 0 || table.Stores != 0 || table.Misses != 1 {
+		t.Errorf("statistics after clear: hits %d, misses %d, stores %d", table.Hits, table.Misses, table.Stores)
+	}
+}
+
+func TestZeroKey(t *testing.T) {
+	// the empty slot is no entry for the key 0
+	table := New[int](4)
+	if _, _, ok := table.Get(0); ok {
+		t.Fatal("empty slot found for key 0")
+	}
+	table.Put(0, 0, 7)
+	if value, _, ok := table.Get(0); !ok || value != 7 {
+		t.Errorf("key 0: %d, %v", value, ok)
+	}
+}
@@ -2,14 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
 	"net/http"
+	"strings"
 )
 
 const K = 32 // constant for Elo calculation
 
+const START_ELO = 1000 // elo of new players
+
+const PLAYER_NAME_MAX_LENGTH = 64
+
+// bot names are unique in the arena, ignoring case
+var ErrNameTaken = errors.New("name is already taken")
+
 type HistoryEntry struct {
 	GameID int  `json:"id"`
 	Win    bool `json:"win"`
@@ -23,7 +32,9 @@ type Player struct {
 	Name        string         `json:"name"`
 	CurrentElo  int            `json:"current_elo"`
 	GameHistory []HistoryEntry `json:"game_history"`
-	SecretToken string         `json:"-"`
+	OwnerID     int            `json:"owner_id,omitempty"`
+	Retired     bool           `json:"retired"`
+	TeamID      int            `json:"team_id,omitempty"`
 }
 
 // func (p Player) MarshalJSON() ([]byte, error) {
@@ -59,66 +70,112 @@ func serveDisplayPlayers(w http.ResponseWriter, _ *http.Request) {
 	players, err := DB_Get_Players()
 
 	if err != nil {
-		http.Error(w, "Error fetching players", http.StatusInternalServerError)
+		writeError(w, ERR_INTERNAL, "Error fetching players")
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(players)
 }
 
-func serveSignUp(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Query().Get("name")
+// checkPlayerName returns the trimmed name of a new player or an error
+// message for the client.
+func checkPlayerName(name string) (string, error) {
+	name = strings.TrimSpace(name)
 	if name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
-		return
+		return "", errors.New("Name is required")
+	}
+	if len(name) > PLAYER_NAME_MAX_LENGTH {
+		return "", fmt.Errorf("Name is longer than %d characters", PLAYER_NAME_MAX_LENGTH)
 	}
-	token, err := DB_Create_Player(name)
+	return name, nil
+}
 
+// signUp creates a bot of the owner (0 for none) and returns its id and
+// token. The errors are converted for the client by apiError.
+func signUp(name string, ownerID int) (int, string, error) {
+	name, err := checkPlayerName(name)
 	if err != nil {
-		http.Error(w, "Error creating player", http.StatusInternalServerError)
-		return
+		return 0, "", NewAPIError(ERR_BAD_REQUEST, err.Error())
+	}
+
+	id, token, err := DB_Create_Player(name, ownerID)
+	if err != nil {
+		return 0, "", err
 	}
 
 	err = ensureGamesAreRunning()
 	if err != nil {
 		slog.Error("Error ensuring games are running", "error", err)
 	}
+	return id, token, nil
+}
+
+// createPlayer creates a bot of the owner (0 for none) and writes the error
+// response on failure.
+func createPlayer(w http.ResponseWriter, name string, ownerID int) (int, string, bool) {
+	id, token, err := signUp(name, ownerID)
+	if err != nil {
+		apiErr := apiError(err)
+		if apiErr.Code == ERR_INTERNAL {
+			slog.Error("Error creating player", "error", err)
+			apiErr.Message = "Error creating player"
+		}
+		writeAPIError(w, apiErr)
+		return 0, "", false
+	}
+	return id, token, true
+}
+
+// serveSignUp creates a bot without owner. Owners create their bots with
+// POST /owner/bots instead.
+func serveSignUp(w http.ResponseWriter, r *http.Request) {
+	_, token, ok := createPlayer(w, r.URL.Query().Get("name"), 0)
+	if !ok {
+		return
+	}
 
 	response := map[string]string{"token": token}
 	json.NewEncoder(w).Encode(response)
 }
 
-func serveGetPlayerByToken(w http.ResponseWriter, r *http.Request) {
-	token := r.PathValue("token")
-	if token == "" {
-		http.Error(w, "Token is required", http.StatusBadRequest)
+// serveCreatePlayer is the sign up of the versioned API, the name is sent
+// as JSON body {"name": "..."}.
+func serveCreatePlayer(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input")
 		return
 	}
 
-	player, err := DB_Get_Player_by_Token(token)
-	if err != nil {
-		msg := fmt.Sprint("Player not found (%s)", err.Error())
-		http.Error(w,
-			msg,
-			http.StatusUnauthorized)
+	id, token, ok := createPlayer(w, request.Name, 0)
+	if !ok {
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NewBot{ID: id, Token: token})
+}
 
+// serveGetPlayerByToken returns the player of the token, sent in the
+// Authorization header (/user/me) or in the deprecated path (/user/{token}).
+func serveGetPlayerByToken(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(player)
 }
 
 func InitHttpHandler_Users() {
-	http.HandleFunc("GET /users", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveDisplayPlayers(w, r)
-	})
-
-	http.HandleFunc("GET /user/signup", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveSignUp(w, r)
-	})
-
-	http.HandleFunc("GET /user/{token}", func(w http.ResponseWriter, r *http.Request) {
-		LogRequest(r)
-		serveGetPlayerByToken(w, r)
-	})
+	mux.HandleFunc("GET /users", deprecated("/players", serveDisplayPlayers))
+	mux.HandleFunc("GET /user/signup", deprecated("/players", serveSignUp))
+	mux.HandleFunc("GET /user/me", deprecated("/players/me", serveGetPlayerByToken))
+
+	// deprecated, the token ends up in logs and browser histories
+	mux.HandleFunc("GET /user/{token}", deprecated("/players/me", serveGetPlayerByToken))
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Bots declare the version they play with. Every version has its own rating
+// track, starting from the rating of the version played before, such that
+// the strength of a change shows in the rating of its version. Games are
+// rated for the versions that played them, the rating of the player is the
+// rating of its current version.
+const VERSION_MAX_LENGTH = 64
+
+type PlayerVersion struct {
+	ID        int          `json:"id"`
+	Version   string       `json:"version"` // empty for games before the first declared version
+	Elo       int          `json:"elo"`
+	SeedElo   int          `json:"seed_elo"`   // rating the version started with
+	EloChange int          `json:"elo_change"` // Elo - SeedElo
+	CreatedAt int64        `json:"created_at"`
+	Current   bool         `json:"current"`
+	Results   ResultCounts `json:"results"`
+}
+
+// serveSetVersion switches the bot of the token to the version of the body,
+// e.g. {"version": "v2"}. Running games keep the version they started with.
+func serveSetVersion(w http.ResponseWriter, r *http.Request) {
+	player := authenticate(w, r)
+	if player == nil {
+		return
+	}
+
+	var request struct {
+		Version string `json:"version"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, ERR_INVALID_JSON, "Invalid JSON input")
+		return
+	}
+	version := strings.TrimSpace(request.Version)
+	if version == "" || len(version) > VERSION_MAX_LENGTH {
+		writeError(w, ERR_BAD_REQUEST, fmt.Sprintf("Version has to be 1 to %d characters long", VERSION_MAX_LENGTH))
+		return
+	}
+
+	current, created, err := DB_Set_Player_Version(player.ID, version)
+	if err != nil {
+		slog.Error("Error setting version", "playerID", player.ID, "version", version, "error", err)
+		writeError(w, ERR_INTERNAL, "Internal server error")
+		return
+	}
+	if created {
+		slog.Info("New player version", "playerID", player.ID, "version", version, "elo", current.Elo)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(current)
+}
+
+func InitHttpHandler_Versions() {
+	mux.HandleFunc("PUT /user/me/version", deprecated("/players/me/version", serveSetVersion))
+}
@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestGameParticipantsRatingOfVersion(t *testing.T) {
+	discardLogs(t)
+	openTestDB(t)
+
+	alpha, _, err := DB_Create_Player("alpha", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	beta, _, err := DB_Create_Player("beta", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createGame := func() *Game {
+		t.Helper()
+		state := NewGameState(5, 5)
+		id, err := DB_Create_Game(alpha, beta, &state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		game, err := DB_Get_Game(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return game
+	}
+
+	// the first game is played by the first version of alpha and ends after
+	// a game of the second version
+	first := createGame()
+	if _, _, err := DB_Set_Player_Version(alpha, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	second := createGame()
+	if err := endGame(second, 1, TERMINATION_RESIGNATION); err != nil {
+		t.Fatal(err)
+	}
+	if err := endGame(first, 1, TERMINATION_RESIGNATION); err != nil {
+		t.Fatal(err)
+	}
+
+	participants, err := DB_Get_Game_Participants(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := participants[0]; p.EloBefore != START_ELO || p.EloAfter <= START_ELO {
+		t.Errorf("second game: alpha rated %d -> %d", p.EloBefore, p.EloAfter)
+	}
+
+	participants, err = DB_Get_Game_Participants(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := participants[0]; p.EloBefore != START_ELO || p.EloAfter <= START_ELO {
+		t.Errorf("first game: alpha rated %d -> %d, want the track of the first version", p.EloBefore, p.EloAfter)
+	}
+	// beta has one version, its second game starts with the rating after the first
+	if p := participants[1]; p.EloBefore >= START_ELO || p.EloAfter >= p.EloBefore {
+		t.Errorf("first game: beta rated %d -> %d", p.EloBefore, p.EloAfter)
+	}
+
+	// running games show the current rating of the playing version
+	running := createGame()
+	participants, err = DB_Get_Game_Participants(running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, _, err := DB_Set_Player_Version(alpha, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if participants[0].EloBefore != v2.Elo || participants[0].EloAfter != 0 {
+		t.Errorf("running game: alpha rated %d -> %d, want %d", participants[0].EloBefore, participants[0].EloAfter, v2.Elo)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+// Zobrist hashing: every (cell, player) combination has a pseudo random key,
+// the hash of a position is the xor of the keys of all pawns, plus the side
+// key if player 2 is to move. The keys are derived from the coordinates, so
+// hashes are stable across restarts and do not depend on the board size.
+
+const ZOBRIST_SEED uint64 = 0x52_4c_41_72_65_6e_61 // "RLArena"
+
+// splitmix64 is used as a fast, well distributed hash for the keys
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func zobristKey(row int, col int, player int) uint64 {
+	return splitmix64(ZOBRIST_SEED ^ uint64(row)<<32 ^ uint64(col)<<2 ^ uint64(player))
+}
+
+var zobristSideKey = splitmix64(ZOBRIST_SEED ^ 0xffff_ffff_ffff_ffff)
+
+// zobristEnPassantKey marks the column of a pawn that can be captured en passant
+func zobristEnPassantKey(col int) uint64 {
+	return splitmix64(ZOBRIST_SEED ^ 0xeeee_0000_0000_0000 ^ uint64(col))
+}
+
+// zobristHash computes the hash of a board from scratch.
+func zobristHash(board [][]int, nextPlayer int) uint64 {
+	var hash uint64
+	for row := range board {
+		for col, cell := range board[row] {
+			if cell != 0 {
+				hash ^= zobristKey(row, col, cell)
+			}
+		}
+	}
+	if nextPlayer == 2 {
+		hash ^= zobristSideKey
+	}
+	return hash
+}
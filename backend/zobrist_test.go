@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var ruleVariants = []RuleVariant{
+	{},
+	{DoubleStep: true},
+	{DoubleStep: true, EnPassant: true},
+	{DoubleStep: true, EnPassant: true, WinOnNoPawns: true, StalemateLoses: true},
+}
+
+// scratchHash computes the hash of the state without its incremental hash.
+func scratchHash(g *GameState) uint64 {
+	hash := zobristHash(g.Board, g.NextPlayer())
+	if _, col, ok := g.enPassantSquare(); ok {
+		hash ^= zobristEnPassantKey(col)
+	}
+	return hash
+}
+
+func TestIncrementalHashMatchesScratch(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for _, rules := range ruleVariants {
+		for _, size := range bitboardSizes {
+			for game := 0; game < 20; game++ {
+				state := NewGameState(size[0], size[1])
+				state.Rules = rules
+				for !state.IsEnd() {
+					if state.Hash() != scratchHash(&state) {
+						t.Fatalf("%s (%s): hash %016x, from scratch %016x", state.Position(), rules, state.Hash(), scratchHash(&state))
+					}
+					moves := state.PossibleMoves()
+					state.applyAction(moves[rng.Intn(len(moves))])
+				}
+				if state.Hash() != scratchHash(&state) {
+					t.Fatalf("%s (%s): hash of the final position differs", state.Position(), rules)
+				}
+			}
+		}
+	}
+}
+
+// playMoves plays the moves given in algebraic notation.
+func playMoves(t *testing.T, g *GameState, moves ...string) {
+	t.Helper()
+	for _, move := range moves {
+		turn, err := g.ParseMove(move)
+		if err != nil {
+			t.Fatalf("%s: %v", g.Position(), err)
+		}
+		g.applyAction(turn)
+	}
+}
+
+func TestHashIdentifiesPositions(t *testing.T) {
+	// the same position reached by different move orders
+	a := NewGameState(5, 3)
+	playMoves(t, &a, "a1-a2", "a5-a4", "c1-c2", "c5-c4")
+	b := NewGameState(5, 3)
+	playMoves(t, &b, "c1-c2", "c5-c4", "a1-a2", "a5-a4")
+	if a.Hash() != b.Hash() {
+		t.Errorf("transposed positions have the hashes %016x and %016x", a.Hash(), b.Hash())
+	}
+
+	// one pawn on another cell
+	c := NewGameState(5, 3)
+	playMoves(t, &c, "a1-a2", "a5-a4", "c1-c2", "b5-b4")
+	if c.Hash() == a.Hash() {
+		t.Error("different positions have the same hash")
+	}
+}
@@ -14,6 +14,8 @@ AWAIT_NEW_GAMES = 5  # seconds
 
 PATH_TO_USERTOKEN_FILE = "./usertokens"
 
+API = "/api/v1"
+
 
 class Client():
     def __init__(self, username: str, strategy: Strategy,  urlbase: str = "http://127.0.0.1:8081"):
@@ -33,14 +35,13 @@ class Client():
 
         if self.username in usertokens.keys():
             self.token = usertokens[self.username]
-            print("Using cached token for", self.username, self.token)
+            print("Using cached token for", self.username)
             return True
 
-        resp = requests.get(
-            self.urlbase + "/user/signup?name={}".format(self.username))
-        print("Sign up response:", resp.status_code, resp.text)
+        resp = self.request("POST", "/players", json={"name": self.username})
+        print("Sign up response:", resp.status_code)
 
-        if resp.status_code != 200:
+        if resp.status_code != 201:
             print("Error during signUp:", resp.status_code, resp.text)
             return False
 
@@ -53,15 +54,38 @@ class Client():
             json.dump(usertokens, f)
         return True
 
+    def headers(self):
+        return {"Authorization": "Bearer {}".format(self.token)}
+
+    def request(self, method, path, **kwargs):
+        # the server answers 429 when the bot sends too many requests,
+        # wait as long as it asks for and try again
+        while True:
+            resp = requests.request(method, self.urlbase + API + path, **kwargs)
+            if resp.status_code != 429:
+                return resp
+            wait = float(resp.headers.get("Retry-After", 1))
+            print("Rate limited, retrying in {}s".format(wait))
+            time.sleep(wait)
+
+    def setVersion(self, version):
+        # every version gets its own rating, starting from the previous one
+        resp = self.request("PUT", "/players/me/version", json={"version": version}, headers=self.headers())
+        if resp.status_code == 200 or resp.status_code == 201:
+            return True
+
+        print("Error during 'PUT /players/me/version':",
+              resp.status_code, resp.text)
+        return False
+
     def getActiveGames(self):
         time.sleep(SLEEP_TIME)
 
-        resp = requests.get(
-            self.urlbase + "/games/active/{}".format(self.token))
+        resp = self.request("GET", "/players/me/games", headers=self.headers())
         if resp.status_code == 200:
             return resp.json()
 
-        print("Error during 'GET /games/active/{}':".format(self.token),
+        print("Error during 'GET /players/me/games':",
               resp.status_code, resp.text)
         return None
 
@@ -76,7 +100,7 @@ class Client():
         }
 
     def printGameState(self, gameId):
-        resp = requests.get(self.urlbase + "/game/{}/state".format(gameId))
+        resp = self.request("GET", "/games/{}".format(gameId))
         if resp.status_code == 200:
             game = resp.json()
             print("({})Game state: {} vs {}".format())
@@ -95,16 +119,16 @@ class Client():
                 ))
             return True
 
-        print("Error during 'GET /game/{}/state:".format(gameId),
+        print("Error during 'GET /games/{}':".format(gameId),
               resp.status_code, resp.text)
         return False
 
     def getGame(self, gameId):
-        resp = requests.get(self.urlbase + "/game/{}/state".format(gameId))
+        resp = self.request("GET", "/games/{}".format(gameId))
         if resp != 200:
             return resp.json()
 
-        print("Error during 'GET /game/{}/state:".format(gameId),
+        print("Error during 'GET /games/{}':".format(gameId),
               resp.status_code, resp.text)
         return None
 
@@ -131,26 +155,47 @@ class Client():
                 "action": selected
             })
 
-        resp = requests.post(
-            self.urlbase + "/games/actions?token={}".format(self.token), json=payloads)
+        resp = self.request("POST", "/games/actions", json=payloads, headers=self.headers())
         if resp.status_code == 200 or resp.status_code == 201:
+            # actions of other games are applied even if some fail
+            for gameId, error in resp.json()["errors"].items():
+                print("Action for game {} failed: {} ({})".format(
+                    gameId, error["code"], error["message"]))
             return True
 
-        print("Error during 'POST /games/actions?token={}".format(self.token),
+        print("Error during 'POST /games/actions'",
               resp.status_code, resp.text)
         return False
 
     def performAction(self, gameId, action):
         time.sleep(SLEEP_TIME)
 
-        print("Performing action token={} action={}".format(self.token, action))
-        resp = requests.post(
-            self.urlbase + "/game/{}/action?token={}".format(gameId, self.token), json=action)
+        print("Performing action game={} action={}".format(gameId, action))
+        resp = self.request("POST", "/games/{}/actions".format(gameId), json=action, headers=self.headers())
         if resp.status_code == 200 or resp.status_code == 201:
 
             return True
 
-        print("Error during 'POST /game/{}/action?token={}".format(gameId, self.token),
+        print("Error during 'POST /games/{}/actions'".format(gameId),
+              resp.status_code, resp.text)
+        return False
+
+    def resign(self, gameId):
+        resp = self.request("POST", "/games/{}/resign".format(gameId), headers=self.headers())
+        if resp.status_code == 200:
+            return True
+
+        print("Error during 'POST /games/{}/resign'".format(gameId),
+              resp.status_code, resp.text)
+        return False
+
+    def offerDraw(self, gameId):
+        # accepts the draw if the opponent offered one before
+        resp = self.request("POST", "/games/{}/draw".format(gameId), headers=self.headers())
+        if resp.status_code == 200:
+            return True
+
+        print("Error during 'POST /games/{}/draw'".format(gameId),
               resp.status_code, resp.text)
         return False
 